@@ -0,0 +1,150 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"claude-monitor/internal/monitor"
+)
+
+// AccessPolicy restricts actions on processes on machines shared by
+// several users. Reading is not restricted.
+type AccessPolicy struct {
+	// Enabled limits killing, renaming and terminal actions to the owner
+	// of a process and changing settings to admins
+	Enabled bool `json:"enabled"`
+	// Admins are user names or UIDs allowed to act on every process; root
+	// is always an admin
+	Admins []string `json:"admins"`
+	// UserHeader is a request header, e.g. "X-Forwarded-User", holding the
+	// user name set by an authenticating reverse proxy. It is only trusted
+	// on connections whose socket is owned by ProxyUser.
+	UserHeader string `json:"userHeader"`
+	// ProxyUser is the user name or UID the reverse proxy runs as; the
+	// header is ignored while it is empty
+	ProxyUser string `json:"proxyUser"`
+}
+
+// isUser reports whether name is the user name or UID of uid
+func isUser(users *monitor.PasswdDB, name string, uid int) bool {
+	return uid >= 0 && (name == users.Name(uid) || name == strconv.Itoa(uid))
+}
+
+// Caller is the user making a request
+type Caller struct {
+	UID  int    `json:"uid"`
+	User string `json:"user"`
+	// Authenticated is false if the user could not be determined, e.g.
+	// for clients on other machines
+	Authenticated bool `json:"authenticated"`
+	Admin         bool `json:"admin"`
+	// Method is "socket" when the kernel reported the owner of the client
+	// socket and "header" when a reverse proxy named the user
+	Method string `json:"method,omitempty"`
+	// Enforced reports whether the access policy is enabled
+	Enforced bool `json:"enforced"`
+}
+
+// caller identifies the user of a request
+func (h *Handler) caller(r *http.Request) Caller {
+	policy := h.GetSettings().Access
+	users := h.processMonitor.Users()
+	c := Caller{UID: -1, Enforced: policy.Enabled}
+
+	remote, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return c
+	}
+	remoteAddr := net.TCPAddrFromAddrPort(remote)
+
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr)
+	if !ok {
+		return c
+	}
+	peer, err := monitor.PeerUID("/proc", local, remoteAddr)
+	if err != nil {
+		return c
+	}
+	c.UID, c.User, c.Authenticated, c.Method = peer, users.Name(peer), true, "socket"
+
+	// Any local user can set the header, so only the proxy may name a user
+	name := r.Header.Get(policy.UserHeader)
+	if policy.UserHeader != "" && name != "" && policy.ProxyUser != "" && isUser(users, policy.ProxyUser, peer) {
+		c.UID, c.User, c.Method = -1, name, "header"
+		if uid, ok := users.UID(name); ok {
+			c.UID = uid
+		}
+	}
+
+	if c.Authenticated {
+		c.Admin = c.UID == 0
+		for _, admin := range policy.Admins {
+			if admin == c.User || (c.UID >= 0 && admin == strconv.Itoa(c.UID)) {
+				c.Admin = true
+			}
+		}
+	}
+	return c
+}
+
+// authorizeAdmin writes 403 unless the policy is off or the caller is an
+// admin
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	c := h.caller(r)
+	if !c.Enforced || c.Admin {
+		return true
+	}
+	http.Error(w, "Permission denied", http.StatusForbidden)
+	return false
+}
+
+// authorizeProcess writes 403 unless the policy is off, the caller is an
+// admin or the caller owns the process with the given PID or identity
+func (h *Handler) authorizeProcess(w http.ResponseWriter, r *http.Request, key string) bool {
+	c := h.caller(r)
+	if !c.Enforced || c.Admin {
+		return true
+	}
+	if p, ok := h.findProcess(key); ok && c.Authenticated && c.UID >= 0 && p.UID == c.UID {
+		return true
+	}
+	http.Error(w, "Permission denied", http.StatusForbidden)
+	return false
+}
+
+func (h *Handler) handleMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, h.caller(r))
+}
+
+// handleUsers serves the resource use of each user's sessions
+func (h *Handler) handleUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.RLock()
+	processes := h.latestProcesses
+	h.mu.RUnlock()
+
+	writeJSON(w, monitor.AggregateUsers(processes))
+}
+
+// filterByUser keeps the processes of a user name or UID
+func (h *Handler) filterByUser(processes []monitor.ClaudeProcess, user string) []monitor.ClaudeProcess {
+	uid, ok := h.processMonitor.Users().UID(user)
+	filtered := []monitor.ClaudeProcess{}
+	for _, p := range processes {
+		if (ok && p.UID == uid) || p.User == user {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
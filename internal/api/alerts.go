@@ -0,0 +1,232 @@
+package api
+
+import (
+	"fmt"
+
+	"claude-monitor/internal/monitor"
+)
+
+// Alert is a single threshold violation found by CheckAlerts
+type Alert struct {
+	Type string `json:"type"`
+	PID  int    `json:"pid,omitempty"`
+	// ProcessID is the identity of the process, stable across renames
+	ProcessID string `json:"processId,omitempty"`
+	Process   string `json:"process,omitempty"`
+	Sensor    string `json:"sensor,omitempty"`
+	User      string `json:"user,omitempty"`
+	Message   string `json:"message"`
+}
+
+// Alert types
+const (
+	AlertCPU         = "cpu"
+	AlertTemperature = "temperature"
+	AlertIOWrite     = "io_write"
+	AlertFD          = "fd"
+	AlertThrottle    = "throttle"
+	AlertSensorTemp  = "sensor_temperature"
+	AlertSystemCPU   = "system_cpu"
+	AlertMemory      = "memory"
+	AlertPressure    = "pressure"
+	AlertBattery     = "battery"
+	AlertDiskSpace   = "disk_space"
+	AlertUserShare   = "user_share"
+)
+
+// fairShareMinCPU is the combined CPU% of all sessions, one core, below
+// which there is no contention worth a fair-share alert
+const fairShareMinCPU = 100
+
+// CheckAlerts evaluates the latest sample taken by RecordHistory against
+// the configured thresholds
+func (h *Handler) CheckAlerts() []Alert {
+	h.mu.RLock()
+	settings := h.settings
+	point := h.latest
+	processes := h.latestProcesses
+	sensors := h.latestSensors
+	power := h.latestPower
+	disk := h.latestDisk
+	h.mu.RUnlock()
+
+	if !settings.AlertsEnabled {
+		return nil
+	}
+
+	var alerts []Alert
+
+	// Check temperature
+	if point.Temperature != nil && *point.Temperature >= settings.TempThreshold {
+		alerts = append(alerts, Alert{
+			Type: AlertTemperature,
+			Message: fmt.Sprintf("High temperature detected: %.0f°%s",
+				convertTemp(*point.Temperature, settings.TempUnit), settings.TempUnit),
+		})
+	}
+
+	// Check every temperature sensor against its own limit
+	for _, s := range sensors {
+		if s.Type != monitor.SensorTemperature {
+			continue
+		}
+		limit, overridden := sensorTempLimit(s, settings.SensorThresholds)
+		// An override replaces the hardware alarm too; 0 disables alerts
+		exceeded := limit > 0 && s.Value >= limit
+		if exceeded || (!overridden && s.Alarm) {
+			alerts = append(alerts, Alert{
+				Type:   AlertSensorTemp,
+				Sensor: s.ID(),
+				Message: fmt.Sprintf("High temperature on %s: %.0f°%s (limit %.0f°%s)",
+					s.ID(), convertTemp(s.Value, settings.TempUnit), settings.TempUnit,
+					convertTemp(limit, settings.TempUnit), settings.TempUnit),
+			})
+		}
+	}
+
+	// Check machine-wide load
+	system := point.System
+	if settings.SystemCPUThreshold > 0 && system.CPUPercent >= settings.SystemCPUThreshold {
+		alerts = append(alerts, Alert{
+			Type: AlertSystemCPU,
+			Message: fmt.Sprintf("System CPU usage at %.0f%% (Claude %.0f%%)",
+				system.CPUPercent, system.ClaudeCPUPercent),
+		})
+	}
+	if settings.MemoryThreshold > 0 && system.MemUsedPercent >= settings.MemoryThreshold {
+		alerts = append(alerts, Alert{
+			Type:    AlertMemory,
+			Message: fmt.Sprintf("System memory usage at %.0f%%", system.MemUsedPercent),
+		})
+	}
+	if settings.PressureThreshold > 0 {
+		for _, p := range []struct {
+			resource string
+			value    float64
+		}{
+			{"CPU", system.CPUPressure},
+			{"memory", system.MemoryPressure},
+			{"I/O", system.IOPressure},
+		} {
+			if p.value >= settings.PressureThreshold {
+				alerts = append(alerts, Alert{
+					Type:    AlertPressure,
+					Message: fmt.Sprintf("High %s pressure: stalled %.0f%% of the last 10s", p.resource, p.value),
+				})
+			}
+		}
+	}
+
+	// Check battery
+	if power.OnBattery && settings.BatteryAlertPercent > 0 && power.CapacityPercent < settings.BatteryAlertPercent {
+		alerts = append(alerts, Alert{
+			Type: AlertBattery,
+			Message: fmt.Sprintf("Battery low: %.0f%%, Claude sessions drawing about %.1f W of %.1f W",
+				power.CapacityPercent, power.ClaudeDrainWatts, power.DrainWatts),
+		})
+	}
+
+	// Check free space, once per filesystem
+	if settings.DiskFreeThreshold > 0 {
+		seen := make(map[uint64]bool)
+		for _, d := range disk {
+			if d.Error != "" || d.TotalMB == 0 || seen[d.Device] {
+				continue
+			}
+			seen[d.Device] = true
+			if d.FreePercent < settings.DiskFreeThreshold {
+				alerts = append(alerts, Alert{
+					Type: AlertDiskSpace,
+					Message: fmt.Sprintf("Low disk space for %s: %.0f%% free (%.1f GB)",
+						d.Path, d.FreePercent, d.AvailableMB/1024),
+				})
+			}
+		}
+	}
+
+	// Check fair share between users when the sessions compete for CPU
+	if settings.UserShareThreshold > 0 {
+		users := monitor.AggregateUsers(processes)
+		var total float64
+		for _, u := range users {
+			total += u.CPUPercent
+		}
+		for _, u := range users {
+			if len(users) > 1 && total >= fairShareMinCPU && u.CPUSharePercent >= settings.UserShareThreshold {
+				alerts = append(alerts, Alert{
+					Type: AlertUserShare,
+					User: u.User,
+					Message: fmt.Sprintf("User %s takes %.0f%% of the CPU used by Claude sessions of %d users (%d sessions, %.0f%%)",
+						u.User, u.CPUSharePercent, len(users), u.Sessions, u.CPUPercent),
+				})
+			}
+		}
+	}
+
+	// Check thermal throttling
+	if settings.ThrottleAlerts && point.Throttling {
+		freq := h.cpufreq.Last()
+		alerts = append(alerts, Alert{
+			Type: AlertThrottle,
+			Message: fmt.Sprintf("CPU is thermally throttling: %d core and %d package events, %.0f MHz average",
+				freq.CoreThrottleEvents, freq.PackageThrottleEvents, freq.AvgMHz),
+		})
+	}
+
+	// Check processes, with a stricter CPU limit on battery if configured
+	cpuThreshold := settings.CPUThreshold
+	if power.OnBattery && settings.BatteryCPUThreshold > 0 {
+		cpuThreshold = settings.BatteryCPUThreshold
+	}
+	for _, p := range processes {
+		if p.CPUPercent >= cpuThreshold {
+			alerts = append(alerts, Alert{
+				Type:      AlertCPU,
+				PID:       p.PID,
+				ProcessID: p.ID,
+				Process:   p.Name,
+				Message:   fmt.Sprintf("High CPU usage on process %s: %.1f%%", p.Name, p.CPUPercent),
+			})
+		}
+
+		writeMBps := p.IO.WriteBytesPerSec / (1024 * 1024)
+		if settings.IOWriteThreshold > 0 && writeMBps >= settings.IOWriteThreshold {
+			alerts = append(alerts, Alert{
+				Type:      AlertIOWrite,
+				PID:       p.PID,
+				ProcessID: p.ID,
+				Process:   p.Name,
+				Message:   fmt.Sprintf("High disk writes on process %s: %.1f MB/s", p.Name, writeMBps),
+			})
+		}
+
+		if settings.FDThreshold > 0 && p.FDUsagePercent >= settings.FDThreshold {
+			alerts = append(alerts, Alert{
+				Type:      AlertFD,
+				PID:       p.PID,
+				ProcessID: p.ID,
+				Process:   p.Name,
+				Message:   fmt.Sprintf("Process %s is close to its open file limit: %.0f%% used", p.Name, p.FDUsagePercent),
+			})
+		}
+	}
+
+	return alerts
+}
+
+// sensorTempLimit returns the alert limit of a temperature sensor: the
+// override from settings, else its hardware high limit, else its critical
+// limit. 0 means the sensor has no limit. overridden is true if the limit
+// comes from settings.
+func sensorTempLimit(s monitor.Sensor, overrides map[string]float64) (limit float64, overridden bool) {
+	if limit, ok := overrides[s.ID()]; ok {
+		return limit, true
+	}
+	if s.Max != nil && *s.Max > 0 {
+		return *s.Max, false
+	}
+	if s.Crit != nil {
+		return *s.Crit, false
+	}
+	return 0, false
+}
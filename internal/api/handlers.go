@@ -2,22 +2,127 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"claude-monitor/internal/monitor"
 )
 
-// Settings represents user-configurable alert settings
+// writeJSON encodes v as the JSON response body
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Temperature units of the API
+const (
+	TempUnitCelsius    = "C"
+	TempUnitFahrenheit = "F"
+)
+
+// Settings represents user-configurable alert settings. GetSettings shares
+// the maps and slices of the current settings with its callers, so they
+// are replaced rather than modified.
 type Settings struct {
-	CPUThreshold  float64 `json:"cpuThreshold"`
+	CPUThreshold float64 `json:"cpuThreshold"`
+	// TempThreshold is always in °C, regardless of TempUnit
 	TempThreshold float64 `json:"tempThreshold"`
 	AlertsEnabled bool    `json:"alertsEnabled"`
+	// IOWriteThreshold is the write rate in MB/s of a process tree above
+	// which an alert is raised
+	IOWriteThreshold float64 `json:"ioWriteThreshold"`
+	// FDThreshold is the percentage of RLIMIT_NOFILE in use by any
+	// process of a session above which an alert is raised
+	FDThreshold float64 `json:"fdThreshold"`
+	// ThrottleAlerts raises an alert while the CPU is thermally throttled
+	ThrottleAlerts bool `json:"throttleAlerts"`
+
+	// PrimarySensor is the "chip/label" ID of the sensor used as the main
+	// temperature; empty selects one automatically
+	PrimarySensor string `json:"primarySensor"`
+	// TempAggregation is "sensor", "max" or "avg" of the CPU cores
+	TempAggregation string `json:"tempAggregation"`
+	// TempUnit is the unit temperatures are reported in by the API
+	TempUnit string `json:"tempUnit"`
+	// SensorThresholds overrides the hardware high/crit limit of
+	// individual temperature sensors, keyed by "chip/label", in °C
+	SensorThresholds map[string]float64 `json:"sensorThresholds"`
+
+	// SystemCPUThreshold is the machine-wide CPU% above which an alert is
+	// raised
+	SystemCPUThreshold float64 `json:"systemCpuThreshold"`
+	// MemoryThreshold is the percentage of RAM in use above which an
+	// alert is raised
+	MemoryThreshold float64 `json:"memoryThreshold"`
+	// PressureThreshold is the PSI "some" avg10 percentage of CPU, memory
+	// or I/O above which an alert is raised
+	PressureThreshold float64 `json:"pressureThreshold"`
+
+	// BatteryAlertPercent raises an alert when running on battery below
+	// this charge level
+	BatteryAlertPercent float64 `json:"batteryAlertPercent"`
+	// BatteryCPUThreshold replaces CPUThreshold while on battery; 0 keeps
+	// CPUThreshold
+	BatteryCPUThreshold float64       `json:"batteryCpuThreshold"`
+	SuspendOnBattery    SuspendPolicy `json:"suspendOnBattery"`
+
+	// DiskFreeThreshold is the percentage of free space on the filesystem
+	// of a working directory below which an alert is raised
+	DiskFreeThreshold float64                `json:"diskFreeThreshold"`
+	DiskScan          monitor.DiskScanConfig `json:"diskScan"`
+
+	// Aliases names the sessions of a working directory
+	Aliases map[string]string `json:"aliases"`
+
+	// ProjectRules group working directories into projects by prefix,
+	// taking precedence over the git repository
+	ProjectRules []monitor.ProjectRule `json:"projectRules"`
+
+	Access AccessPolicy `json:"access"`
+	// UserShareThreshold is the share of the CPU used by Claude sessions
+	// that one user may take while others run sessions too, in percent;
+	// 0 disables the alert
+	UserShareThreshold float64 `json:"userShareThreshold"`
+}
+
+// Validate checks enumerated settings
+func (s Settings) Validate() error {
+	switch s.TempAggregation {
+	case monitor.TempAggregateSensor, monitor.TempAggregateMax, monitor.TempAggregateAvg:
+	default:
+		return fmt.Errorf("invalid tempAggregation %q", s.TempAggregation)
+	}
+
+	switch s.TempUnit {
+	case TempUnitCelsius, TempUnitFahrenheit:
+	default:
+		return fmt.Errorf("invalid tempUnit %q", s.TempUnit)
+	}
+
+	return nil
+}
+
+// clone returns a copy of s that shares no maps or slices with it
+func (s Settings) clone() Settings {
+	c := s
+	c.SensorThresholds = maps.Clone(s.SensorThresholds)
+	c.Aliases = maps.Clone(s.Aliases)
+	c.ProjectRules = slices.Clone(s.ProjectRules)
+	c.SuspendOnBattery.LowPriority = slices.Clone(s.SuspendOnBattery.LowPriority)
+	c.DiskScan.Excludes = slices.Clone(s.DiskScan.Excludes)
+	c.Access.Admins = slices.Clone(s.Access.Admins)
+	return c
 }
 
 // DefaultSettings returns default settings
@@ -26,16 +131,55 @@ func DefaultSettings() Settings {
 		CPUThreshold:  90.0,
 		TempThreshold: 85.0,
 		AlertsEnabled: true,
+
+		IOWriteThreshold: 50.0,
+		FDThreshold:      80.0,
+		ThrottleAlerts:   true,
+
+		TempAggregation: monitor.TempAggregateSensor,
+		TempUnit:        TempUnitCelsius,
+
+		SystemCPUThreshold: 95.0,
+		MemoryThreshold:    90.0,
+		PressureThreshold:  50.0,
+
+		BatteryAlertPercent: 20.0,
+		SuspendOnBattery: SuspendPolicy{
+			BelowPercent: 20.0,
+		},
+
+		DiskFreeThreshold: 10.0,
+		DiskScan:          monitor.DefaultDiskScanConfig(),
 	}
 }
 
 // Handler holds all API handlers
 type Handler struct {
+	mu             sync.RWMutex
 	processMonitor *monitor.ProcessMonitor
 	tempMonitor    *monitor.TemperatureMonitor
 	history        *monitor.HistoryBuffer
+	connections    *monitor.ConnectionCollector
+	files          *monitor.FileCollector
+	energy         *monitor.EnergyMonitor
+	cpufreq        *monitor.CPUFreqMonitor
+	system         *monitor.SystemMonitor
+	power          *monitor.PowerMonitor
+	disk           *monitor.DiskMonitor
+	projects       *monitor.ProjectTracker
 	settings       Settings
 	settingsPath   string
+
+	// Latest sample taken by RecordHistory, evaluated by CheckAlerts
+	latest          monitor.HistoryPoint
+	latestProcesses []monitor.ClaudeProcess
+	latestSensors   []monitor.Sensor
+	latestPower     monitor.PowerStatus
+	latestDisk      []monitor.DiskUsage
+	latestProjects  []monitor.Project
+
+	// PIDs stopped by the battery suspend policy
+	suspended map[int]bool
 }
 
 // NewHandler creates a new API handler
@@ -44,6 +188,15 @@ func NewHandler(pm *monitor.ProcessMonitor, tm *monitor.TemperatureMonitor, hb *
 		processMonitor: pm,
 		tempMonitor:    tm,
 		history:        hb,
+		connections:    monitor.NewConnectionCollector(),
+		files:          monitor.NewFileCollector(),
+		energy:         monitor.NewEnergyMonitor(),
+		cpufreq:        monitor.NewCPUFreqMonitor(),
+		system:         monitor.NewSystemMonitor(),
+		power:          monitor.NewPowerMonitor(),
+		disk:           monitor.NewDiskMonitor(),
+		projects:       monitor.NewProjectTracker(pm.Users()),
+		suspended:      make(map[int]bool),
 		settings:       DefaultSettings(),
 	}
 
@@ -54,8 +207,15 @@ func NewHandler(pm *monitor.ProcessMonitor, tm *monitor.TemperatureMonitor, hb *
 	}
 	h.settingsPath = filepath.Join(configDir, "claude-monitor", "settings.json")
 
+	// Daily project totals are kept next to the settings
+	projectsPath := filepath.Join(configDir, "claude-monitor", "projects.json")
+	if err := h.projects.Load(projectsPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Ignoring project totals from %s: %v", projectsPath, err)
+	}
+
 	// Load settings
 	h.loadSettings()
+	h.applySettings()
 
 	return h
 }
@@ -63,7 +223,22 @@ func NewHandler(pm *monitor.ProcessMonitor, tm *monitor.TemperatureMonitor, hb *
 // RegisterRoutes registers all API routes
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/processes", h.handleProcesses)
+	mux.HandleFunc("/api/processes/", h.handleProcessDetail)
 	mux.HandleFunc("/api/temperature", h.handleTemperature)
+	mux.HandleFunc("/api/temperature/candidates", h.handleTemperatureCandidates)
+	mux.HandleFunc("/api/sensors", h.handleSensors)
+	mux.HandleFunc("/api/energy", h.handleEnergy)
+	mux.HandleFunc("/api/cpufreq", h.handleCPUFreq)
+	mux.HandleFunc("/api/system", h.handleSystem)
+	mux.HandleFunc("/api/power", h.handlePower)
+	mux.HandleFunc("/api/disk", h.handleDisk)
+	mux.HandleFunc("/api/events", h.handleEvents)
+	mux.HandleFunc("/api/sessions", h.handleSessions)
+	mux.HandleFunc("/api/users", h.handleUsers)
+	mux.HandleFunc("/api/containers", h.handleContainers)
+	mux.HandleFunc("/api/projects", h.handleProjects)
+	mux.HandleFunc("/api/projects/history", h.handleProjectHistory)
+	mux.HandleFunc("/api/me", h.handleMe)
 	mux.HandleFunc("/api/history", h.handleHistory)
 	mux.HandleFunc("/api/kill/", h.handleKill)
 	mux.HandleFunc("/api/settings", h.handleSettings)
@@ -80,6 +255,10 @@ func (h *Handler) handleProcesses(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.energy.Annotate(processes)
+	if user := r.URL.Query().Get("user"); user != "" {
+		processes = h.filterByUser(processes, user)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(processes)
@@ -91,20 +270,260 @@ func (h *Handler) handleTemperature(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	unit := h.GetSettings().TempUnit
 	temps := h.tempMonitor.GetTemperatures()
+	for i := range temps {
+		temps[i].Current = convertTemp(temps[i].Current, unit)
+		temps[i].High = convertOptionalTemp(temps[i].High, unit)
+		temps[i].Crit = convertOptionalTemp(temps[i].Crit, unit)
+	}
 
+	// mainTemp is null without temperature sensors
 	response := struct {
 		Temperatures []monitor.Temperature `json:"temperatures"`
-		MainTemp     float64               `json:"mainTemp"`
+		MainTemp     *float64              `json:"mainTemp"`
+		Unit         string                `json:"unit"`
 	}{
 		Temperatures: temps,
-		MainTemp:     h.tempMonitor.GetMainTemperature(),
+		MainTemp:     convertOptionalTemp(h.mainTemperature(), unit),
+		Unit:         unit,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+func (h *Handler) handleTemperatureCandidates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	unit := h.GetSettings().TempUnit
+	candidates := h.tempMonitor.Candidates()
+	for i := range candidates {
+		candidates[i].Current = convertTemp(candidates[i].Current, unit)
+	}
+
+	writeJSON(w, candidates)
+}
+
+// convertTemp converts a temperature in °C to unit
+func convertTemp(celsius float64, unit string) float64 {
+	if unit != TempUnitFahrenheit {
+		return celsius
+	}
+	return monitor.CelsiusToFahrenheit(celsius)
+}
+
+// convertOptionalTemp converts a temperature that may be missing, like a
+// limit the sensor does not report
+func convertOptionalTemp(celsius *float64, unit string) *float64 {
+	if celsius == nil {
+		return nil
+	}
+	t := convertTemp(*celsius, unit)
+	return &t
+}
+
+// mainTemperature returns the main temperature, or nil without sensors
+func (h *Handler) mainTemperature() *float64 {
+	if t, ok := h.tempMonitor.GetMainTemperature(); ok {
+		return &t
+	}
+	return nil
+}
+
+func (h *Handler) handleSensors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	unit := h.GetSettings().TempUnit
+	sensors := h.tempMonitor.GetSensors()
+	for i := range sensors {
+		if sensors[i].Type != monitor.SensorTemperature || unit != TempUnitFahrenheit {
+			continue
+		}
+		sensors[i].Value = convertTemp(sensors[i].Value, unit)
+		sensors[i].Min = convertOptionalTemp(sensors[i].Min, unit)
+		sensors[i].Max = convertOptionalTemp(sensors[i].Max, unit)
+		sensors[i].Crit = convertOptionalTemp(sensors[i].Crit, unit)
+		sensors[i].Unit = "°F"
+	}
+
+	chips := monitor.GroupSensorsByChip(sensors)
+	if chips == nil {
+		chips = []monitor.SensorChip{}
+	}
+
+	writeJSON(w, chips)
+}
+
+func (h *Handler) handleEnergy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, h.energy.Last())
+}
+
+func (h *Handler) handleCPUFreq(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, h.cpufreq.Last())
+}
+
+func (h *Handler) handleSystem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, h.system.Last())
+}
+
+func (h *Handler) handlePower(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := struct {
+		monitor.PowerStatus
+		SuspendedPIDs []int `json:"suspendedPids"`
+	}{
+		PowerStatus:   h.power.Last(),
+		SuspendedPIDs: h.suspendedPIDs(),
+	}
+
+	writeJSON(w, response)
+}
+
+func (h *Handler) handleDisk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, h.disk.Last())
+}
+
+// handleEvents serves the process lifecycle log, filtered by the query
+// parameters type ("start" or "exit"), id, since (Unix time) and limit
+func (h *Handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := monitor.EventFilter{
+		Type: query.Get("type"),
+		ID:   query.Get("id"),
+	}
+	switch filter.Type {
+	case "", monitor.EventStart, monitor.EventExit:
+	default:
+		http.Error(w, "Invalid type", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if s := query.Get("since"); s != "" {
+		if filter.Since, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		if filter.Limit, err = strconv.Atoi(s); err != nil || filter.Limit < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	writeJSON(w, h.processMonitor.Events().Events(filter))
+}
+
+// handleSessions lists running and recently finished sessions with their
+// resource totals
+func (h *Handler) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, h.processMonitor.Events().Sessions())
+}
+
+// handleProjects serves the live totals of each project
+func (h *Handler) handleProjects(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.RLock()
+	projects := h.latestProjects
+	h.mu.RUnlock()
+	if projects == nil {
+		projects = []monitor.Project{}
+	}
+
+	writeJSON(w, projects)
+}
+
+// handleProjectHistory serves the CPU time and tokens per project per day
+// for the last ?days=N days (default 7, today included) with their totals
+func (h *Handler) handleProjectHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	since := time.Now().AddDate(0, 0, 1-days)
+	daily := h.projects.Days(since)
+	writeJSON(w, struct {
+		Since  string               `json:"since"`
+		Days   []monitor.ProjectDay `json:"days"`
+		Totals []monitor.ProjectDay `json:"totals"`
+	}{
+		Since:  since.Format("2006-01-02"),
+		Days:   daily,
+		Totals: monitor.SumProjectDays(daily),
+	})
+}
+
+// handleContainers serves the sessions grouped by container
+func (h *Handler) handleContainers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.RLock()
+	processes := h.latestProcesses
+	h.mu.RUnlock()
+
+	writeJSON(w, monitor.GroupByContainer(processes))
+}
+
 func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -113,6 +532,18 @@ func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
 
 	history := h.history.GetAll()
 
+	// Temperatures are recorded in °C
+	if unit := h.GetSettings().TempUnit; unit == TempUnitFahrenheit {
+		for i := range history {
+			history[i].Temperature = convertOptionalTemp(history[i].Temperature, unit)
+			for j, s := range history[i].Sensors {
+				if s.Type == monitor.SensorTemperature {
+					history[i].Sensors[j].Value = convertTemp(s.Value, unit)
+				}
+			}
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(history)
 }
@@ -130,19 +561,34 @@ func (h *Handler) handleKill(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid PID", http.StatusBadRequest)
 		return
 	}
+	if !h.authorizeProcess(w, r, path) {
+		return
+	}
 
-	// Send SIGTERM
-	process, err := os.FindProcess(pid)
+	// The sample may be seconds old, so check that the PID still belongs
+	// to the session and its owner right before signalling
+	p, ok := h.findProcess(path)
+	if !ok {
+		http.Error(w, "Process not found", http.StatusNotFound)
+		return
+	}
+	_, uid, err := monitor.LiveProcess(p.ID)
 	if err != nil {
 		http.Error(w, "Process not found", http.StatusNotFound)
 		return
 	}
+	if c := h.caller(r); c.Enforced && !c.Admin && uid != c.UID {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
 
-	err = process.Signal(syscall.SIGTERM)
+	// Send SIGTERM
+	err = syscall.Kill(pid, syscall.SIGTERM)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.processMonitor.Events().NoteSignal(pid, syscall.SIGTERM)
 
 	response := struct {
 		Success bool   `json:"success"`
@@ -156,24 +602,57 @@ func (h *Handler) handleKill(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// maxSettingsBytes bounds the body of a settings update
+const maxSettingsBytes = 1 << 20
+
 func (h *Handler) handleSettings(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(h.settings)
+		json.NewEncoder(w).Encode(h.GetSettings())
 
 	case http.MethodPost:
-		var newSettings Settings
-		if err := json.NewDecoder(r.Body).Decode(&newSettings); err != nil {
+		if !h.authorizeAdmin(w, r) {
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxSettingsBytes))
+		var fields map[string]json.RawMessage
+		if err != nil || json.Unmarshal(body, &fields) != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		// Fields missing from the request keep their current value. Maps
+		// in the request replace the current ones rather than adding keys,
+		// so that entries can be removed.
+		h.mu.Lock()
+		newSettings := h.settings.clone()
+		if _, ok := fields["sensorThresholds"]; ok {
+			newSettings.SensorThresholds = nil
+		}
+		if _, ok := fields["aliases"]; ok {
+			newSettings.Aliases = nil
+		}
+		if err := json.Unmarshal(body, &newSettings); err != nil {
+			h.mu.Unlock()
 			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
 
+		if err := newSettings.Validate(); err != nil {
+			h.mu.Unlock()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		h.settings = newSettings
 		h.saveSettings()
+		h.mu.Unlock()
+		h.applySettings()
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(h.settings)
+		json.NewEncoder(w).Encode(newSettings)
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -186,7 +665,27 @@ func (h *Handler) loadSettings() {
 		return // Use defaults
 	}
 
-	json.Unmarshal(data, &h.settings)
+	loaded := h.settings.clone()
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return
+	}
+	if err := loaded.Validate(); err != nil {
+		log.Printf("Ignoring settings from %s: %v", h.settingsPath, err)
+		return
+	}
+	h.settings = loaded
+}
+
+// applySettings pushes settings that affect the monitors to them
+func (h *Handler) applySettings() {
+	settings := h.GetSettings()
+	h.tempMonitor.SetSelection(monitor.TempSelection{
+		Sensor:      settings.PrimarySensor,
+		Aggregation: settings.TempAggregation,
+	})
+	h.disk.SetScanConfig(settings.DiskScan)
+	h.processMonitor.SetAliases(settings.Aliases)
+	h.projects.SetRules(settings.ProjectRules)
 }
 
 func (h *Handler) saveSettings() {
@@ -203,6 +702,8 @@ func (h *Handler) saveSettings() {
 
 // GetSettings returns current settings
 func (h *Handler) GetSettings() Settings {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	return h.settings
 }
 
@@ -213,46 +714,81 @@ func (h *Handler) RecordHistory() {
 		return
 	}
 
+	energy := h.energy.Sample(processes)
+	freq := h.cpufreq.Sample()
+	system := h.system.Sample(processes)
+	power := h.power.Sample(processes, system.ClaudeCPUShare)
+	disk := h.disk.Sample(processes)
+	projects := h.projects.Sample(processes)
+
 	var snapshots []monitor.ProcessSnapshot
 	for _, p := range processes {
 		snapshots = append(snapshots, monitor.ProcessSnapshot{
-			PID:        p.PID,
-			Name:       p.Name,
-			CPUPercent: p.CPUPercent,
-			MemoryMB:   p.MemoryMB,
+			ID:              p.ID,
+			PID:             p.PID,
+			Name:            p.Name,
+			CPUPercent:      p.CPUPercent,
+			CPUPercentTotal: p.CPUPercentTotal,
+			MemoryMB:        p.MemoryMB,
+			PSSMB:           p.Memory.PSSMB,
+			USSMB:           p.Memory.USSMB,
+			SwapMB:          p.Memory.SwapMB,
+			Threads:         p.Threads,
+
+			ReadBytesPerSec:  p.IO.ReadBytesPerSec,
+			WriteBytesPerSec: p.IO.WriteBytesPerSec,
+			Watts:            p.PowerWatts,
+		})
+	}
+
+	allSensors := h.tempMonitor.GetSensors()
+	var sensors []monitor.SensorSample
+	for _, s := range allSensors {
+		sensors = append(sensors, monitor.SensorSample{
+			ID:    s.ID(),
+			Type:  s.Type,
+			Value: s.Value,
 		})
 	}
 
 	point := monitor.HistoryPoint{
 		Timestamp:   time.Now().Unix(),
-		Temperature: h.tempMonitor.GetMainTemperature(),
+		Temperature: h.mainTemperature(),
 		Processes:   snapshots,
+		Sensors:     sensors,
+
+		PackageWatts: energy.PackageWatts,
+		AvgFreqMHz:   freq.AvgMHz,
+		Throttling:   freq.Throttling,
+
+		CoreThrottleEvents:    freq.CoreThrottleEvents,
+		PackageThrottleEvents: freq.PackageThrottleEvents,
+
+		System: monitor.NewSystemSample(system),
+		Power: monitor.PowerSample{
+			OnBattery:        power.OnBattery,
+			CapacityPercent:  power.CapacityPercent,
+			DrainWatts:       power.DrainWatts,
+			ClaudeDrainWatts: power.ClaudeDrainWatts,
+		},
 	}
 
 	h.history.Add(point)
-}
-
-// CheckAlerts checks if any alerts should be triggered
-func (h *Handler) CheckAlerts() (cpuAlert bool, tempAlert bool, alertProcess string) {
-	if !h.settings.AlertsEnabled {
-		return false, false, ""
-	}
 
-	// Check temperature
-	temp := h.tempMonitor.GetMainTemperature()
-	if temp >= h.settings.TempThreshold {
-		tempAlert = true
-	}
+	h.mu.Lock()
+	h.latest = point
+	h.latestProcesses = processes
+	h.latestSensors = allSensors
+	h.latestPower = power
+	h.latestDisk = disk
+	h.latestProjects = projects
+	h.mu.Unlock()
+}
 
-	// Check CPU
-	processes, _ := h.processMonitor.GetProcesses()
-	for _, p := range processes {
-		if p.CPUPercent >= h.settings.CPUThreshold {
-			cpuAlert = true
-			alertProcess = p.Name
-			break
-		}
+// SaveState writes state that outlives the process, such as the daily
+// project totals
+func (h *Handler) SaveState() {
+	if err := h.projects.Save(); err != nil {
+		log.Printf("Failed to save project totals: %v", err)
 	}
-
-	return
 }
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"claude-monitor/internal/monitor"
+)
+
+// SuspendPolicy pauses low-priority sessions with SIGSTOP while the machine
+// runs on battery below a charge level, and resumes them with SIGCONT once
+// it is back on AC or above the level
+type SuspendPolicy struct {
+	Enabled      bool    `json:"enabled"`
+	BelowPercent float64 `json:"belowPercent"`
+	// LowPriority matches sessions by name or working directory basename,
+	// or by working directory prefix for entries starting with "/"
+	LowPriority []string `json:"lowPriority"`
+}
+
+// matches reports whether p is a low-priority session
+func (sp SuspendPolicy) matches(p monitor.ClaudeProcess) bool {
+	for _, entry := range sp.LowPriority {
+		if strings.HasPrefix(entry, "/") {
+			dir := filepath.Clean(entry)
+			if p.WorkingDir == dir || strings.HasPrefix(p.WorkingDir, dir+"/") {
+				return true
+			}
+			continue
+		}
+		if entry == p.Name || (p.WorkingDir != "" && entry == filepath.Base(p.WorkingDir)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyPolicies enforces the battery suspend policy on the latest sample
+func (h *Handler) ApplyPolicies() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	policy := h.settings.SuspendOnBattery
+	power := h.latestPower
+	active := policy.Enabled && power.OnBattery && power.CapacityPercent < policy.BelowPercent
+
+	if !active {
+		h.resumeSuspendedLocked()
+		return
+	}
+
+	for _, p := range h.latestProcesses {
+		if h.suspended[p.PID] || !policy.matches(p) {
+			continue
+		}
+		if err := syscall.Kill(p.PID, syscall.SIGSTOP); err != nil {
+			log.Printf("Failed to suspend %s (PID %d): %v", p.Name, p.PID, err)
+			continue
+		}
+		log.Printf("Suspended %s (PID %d): on battery at %.0f%%", p.Name, p.PID, power.CapacityPercent)
+		h.suspended[p.PID] = true
+	}
+}
+
+// ResumeSuspended sends SIGCONT to all sessions suspended by a policy, so
+// that none stay stopped when the monitor exits
+func (h *Handler) ResumeSuspended() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resumeSuspendedLocked()
+}
+
+func (h *Handler) resumeSuspendedLocked() {
+	for pid := range h.suspended {
+		if err := syscall.Kill(pid, syscall.SIGCONT); err != nil && err != syscall.ESRCH {
+			log.Printf("Failed to resume PID %d: %v", pid, err)
+			continue
+		}
+		log.Printf("Resumed PID %d", pid)
+		delete(h.suspended, pid)
+	}
+}
+
+// suspendedPIDs returns the sessions currently suspended by a policy
+func (h *Handler) suspendedPIDs() []int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	pids := []int{}
+	for pid := range h.suspended {
+		pids = append(pids, pid)
+	}
+	return pids
+}
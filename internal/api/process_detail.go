@@ -0,0 +1,319 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"claude-monitor/internal/monitor"
+)
+
+// handleProcessDetail serves /api/processes/{pid}/{resource}, where {pid}
+// may also be a process identity
+func (h *Handler) handleProcessDetail(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/processes/")
+	key, resource, _ := strings.Cut(path, "/")
+
+	pid, err := monitor.ParseProcessKey(key)
+	if err != nil {
+		http.Error(w, "Invalid PID", http.StatusBadRequest)
+		return
+	}
+
+	switch resource {
+	case "alias", "terminal/select", "terminal/keys":
+		if r.Method == http.MethodPost && !h.authorizeProcess(w, r, key) {
+			return
+		}
+	}
+
+	switch resource {
+	case "connections":
+		h.handleConnections(w, r, pid)
+	case "files":
+		h.handleFiles(w, r, pid)
+	case "alias":
+		h.handleAlias(w, r, key)
+	case "history":
+		h.handleProcessHistory(w, r, key)
+	case "terminal":
+		h.handleTerminal(w, r, key)
+	case "terminal/select":
+		h.handleTerminalSelect(w, r, key)
+	case "terminal/keys":
+		h.handleTerminalKeys(w, r, key)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) handleConnections(w http.ResponseWriter, r *http.Request, pid int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conns, err := h.connections.Connections(pid)
+	if err != nil {
+		writeProcessError(w, err)
+		return
+	}
+
+	writeJSON(w, conns)
+}
+
+func (h *Handler) handleFiles(w http.ResponseWriter, r *http.Request, pid int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	files, err := h.files.Files(pid)
+	if err != nil {
+		writeProcessError(w, err)
+		return
+	}
+
+	writeJSON(w, files)
+}
+
+// findProcess returns the process of the latest sample with the given PID
+// or identity
+func (h *Handler) findProcess(key string) (monitor.ClaudeProcess, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, p := range h.latestProcesses {
+		if p.ID == key || strconv.Itoa(p.PID) == key {
+			return p, true
+		}
+	}
+	return monitor.ClaudeProcess{}, false
+}
+
+// handleProcessHistory serves the history of one process as columnar
+// series with summaries. A PID refers to the live process; processes that
+// exited are addressed by their identity. The optional since parameter
+// (Unix time) drops older samples.
+func (h *Handler) handleProcessHistory(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var since int64
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+
+	id := key
+	if !strings.Contains(key, "-") {
+		p, ok := h.findProcess(key)
+		if !ok {
+			http.Error(w, "Process not found", http.StatusNotFound)
+			return
+		}
+		id = p.ID
+	}
+
+	series, ok := h.history.ProcessSeries(id, since)
+	if !ok {
+		http.Error(w, "No history for process", http.StatusNotFound)
+		return
+	}
+
+	unit := h.GetSettings().TempUnit
+	for i, t := range series.Temperature {
+		series.Temperature[i] = convertOptionalTemp(t, unit)
+	}
+	series.Summary["temperature"] = monitor.SummarizeOptional(series.Temperature)
+
+	response := struct {
+		monitor.ProcessSeries
+		Unit string `json:"unit"`
+	}{series, unit}
+	writeJSON(w, response)
+}
+
+// handleAlias names all sessions in the working directory of a process.
+// An empty alias restores the default name.
+func (h *Handler) handleAlias(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Alias string `json:"alias"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	p, ok := h.findProcess(key)
+	if !ok {
+		http.Error(w, "Process not found", http.StatusNotFound)
+		return
+	}
+	if p.WorkingDir == "" {
+		http.Error(w, "Process has no working directory", http.StatusConflict)
+		return
+	}
+
+	alias := strings.TrimSpace(req.Alias)
+	h.mu.Lock()
+	aliases := make(map[string]string, len(h.settings.Aliases)+1)
+	for dir, a := range h.settings.Aliases {
+		aliases[dir] = a
+	}
+	if alias == "" {
+		delete(aliases, p.WorkingDir)
+	} else {
+		aliases[p.WorkingDir] = alias
+	}
+	h.settings.Aliases = aliases
+	h.saveSettings()
+	h.mu.Unlock()
+	h.applySettings()
+
+	writeJSON(w, map[string]string{
+		"workingDir": p.WorkingDir,
+		"alias":      alias,
+	})
+}
+
+// maxSendKeys bounds the text sent to a tmux pane in one request
+const maxSendKeys = 4096
+
+func (h *Handler) handleTerminal(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p, ok := h.findProcess(key)
+	if !ok {
+		http.Error(w, "Process not found", http.StatusNotFound)
+		return
+	}
+	if p.Terminal == nil {
+		http.Error(w, "Process has no terminal", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, p.Terminal)
+}
+
+// handleTerminalSelect switches tmux to the pane of a session
+func (h *Handler) handleTerminalSelect(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p, ok := h.findProcess(key)
+	if !ok {
+		http.Error(w, "Process not found", http.StatusNotFound)
+		return
+	}
+	if err := h.processMonitor.SelectTmuxPane(p.ID); err != nil {
+		writeTmuxError(w, err)
+		return
+	}
+
+	writeTmuxSuccess(w, "Selected tmux pane", p)
+}
+
+// handleTerminalKeys sends {"keys": ["C-c"]} as tmux key names or
+// {"text": "...", "enter": true} literally to the pane of a session
+func (h *Handler) handleTerminalKeys(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Keys  []string `json:"keys"`
+		Text  string   `json:"text"`
+		Enter bool     `json:"enter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if (len(req.Keys) == 0) == (req.Text == "") {
+		http.Error(w, "Exactly one of keys and text is required", http.StatusBadRequest)
+		return
+	}
+	size := len(req.Text)
+	for _, k := range req.Keys {
+		size += len(k)
+	}
+	if size > maxSendKeys {
+		http.Error(w, "Too many keys", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	p, ok := h.findProcess(key)
+	if !ok {
+		http.Error(w, "Process not found", http.StatusNotFound)
+		return
+	}
+
+	var err error
+	if req.Text != "" {
+		err = h.processMonitor.SendTmuxKeys(p.ID, []string{req.Text}, true)
+		if err == nil && req.Enter {
+			err = h.processMonitor.SendTmuxKeys(p.ID, []string{"Enter"}, false)
+		}
+	} else {
+		err = h.processMonitor.SendTmuxKeys(p.ID, req.Keys, false)
+	}
+	if err != nil {
+		writeTmuxError(w, err)
+		return
+	}
+
+	writeTmuxSuccess(w, "Keys sent to tmux pane", p)
+}
+
+func writeTmuxSuccess(w http.ResponseWriter, message string, p monitor.ClaudeProcess) {
+	if p.Terminal != nil && p.Terminal.Tmux != nil {
+		message += " " + p.Terminal.Tmux.Target
+	}
+	writeJSON(w, struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}{
+		Success: true,
+		Message: message,
+	})
+}
+
+func writeTmuxError(w http.ResponseWriter, err error) {
+	if errors.Is(err, monitor.ErrNoTmuxPane) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+// writeProcessError maps errors from reading /proc/{pid} to a status code
+func writeProcessError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "Process not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "Permission denied", http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,231 @@
+package monitor
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Session modes of the Claude CLI
+const (
+	ModeInteractive = "interactive"
+	// ModePrint is a one-shot "claude -p" run
+	ModePrint = "print"
+	// ModeHeadless is a print run driven over stream-json, as by the SDK
+	ModeHeadless = "headless"
+)
+
+const (
+	maxArgLength = 200
+	redacted     = "[redacted]"
+)
+
+// CommandInfo is the command line of a Claude process and what it says
+// about the session
+type CommandInfo struct {
+	Exe  string   `json:"exe"`
+	Args []string `json:"args"`
+
+	Mode           string `json:"mode"`
+	Model          string `json:"model,omitempty"`
+	Resume         bool   `json:"resume"`
+	ResumeSession  string `json:"resumeSession,omitempty"`
+	Continue       bool   `json:"continue"`
+	PermissionMode string `json:"permissionMode,omitempty"`
+	MCPConfig      bool   `json:"mcpConfig"`
+	OutputFormat   string `json:"outputFormat,omitempty"`
+
+	// Env holds the allowlisted environment variables that are set
+	Env map[string]string `json:"env"`
+}
+
+// envAllowlist are the environment variables exposed by the API. Values of
+// URL variables have their credentials removed.
+var envAllowlist = map[string]bool{
+	"ANTHROPIC_MODEL":               true,
+	"ANTHROPIC_SMALL_FAST_MODEL":    true,
+	"ANTHROPIC_BASE_URL":            true,
+	"CLAUDE_CONFIG_DIR":             true,
+	"CLAUDE_CODE_ENTRYPOINT":        true,
+	"CLAUDE_CODE_USE_BEDROCK":       true,
+	"CLAUDE_CODE_USE_VERTEX":        true,
+	"CLAUDE_CODE_MAX_OUTPUT_TOKENS": true,
+	"DISABLE_TELEMETRY":             true,
+	"MCP_TIMEOUT":                   true,
+	"HTTP_PROXY":                    true,
+	"HTTPS_PROXY":                   true,
+	"NO_PROXY":                      true,
+	"http_proxy":                    true,
+	"https_proxy":                   true,
+	"no_proxy":                      true,
+	"TERM_PROGRAM":                  true,
+}
+
+// sensitiveWords mark variables and flags whose values are never exposed,
+// even if allowlisted by mistake
+var sensitiveWords = []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "PASSWD", "AUTH", "CREDENTIAL", "COOKIE"}
+
+func isSensitive(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, word := range sensitiveWords {
+		if strings.Contains(upper, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// readCommandInfo reads cmdline, exe and environ of a process. environ is
+// only readable for processes of the same user unless running as root.
+func readCommandInfo(procRoot string, pid int) (*CommandInfo, error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+
+	data, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	info := parseCommandLine(splitNul(data))
+	info.Exe, _ = os.Readlink(filepath.Join(dir, "exe"))
+
+	if environ, err := os.ReadFile(filepath.Join(dir, "environ")); err == nil {
+		info.Env = filterEnv(splitNul(environ))
+	}
+	if info.Model == "" {
+		info.Model = info.Env["ANTHROPIC_MODEL"]
+	}
+
+	return info, nil
+}
+
+func splitNul(data []byte) []string {
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(string(data), "\x00")
+}
+
+// parseCommandLine interprets the arguments of the Claude CLI. A leading
+// interpreter and script, as in "node /usr/lib/.../cli.js", are skipped.
+func parseCommandLine(argv []string) *CommandInfo {
+	info := &CommandInfo{
+		Mode: ModeInteractive,
+		Args: []string{},
+		Env:  map[string]string{},
+	}
+
+	args := argv
+	if len(args) > 1 {
+		switch filepath.Base(args[0]) {
+		case "node", "bun", "deno":
+			args = args[2:]
+		default:
+			args = args[1:]
+		}
+	} else {
+		args = nil
+	}
+
+	printMode, streamJSON := false, false
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		// next returns the value of a flag given as "--flag value"
+		next := func() string {
+			if hasValue {
+				return value
+			}
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+
+		switch name {
+		case "-p", "--print":
+			printMode = true
+		case "--model":
+			info.Model = next()
+		case "-r", "--resume":
+			info.Resume = true
+			info.ResumeSession = next()
+		case "-c", "--continue":
+			info.Continue = true
+		case "--permission-mode":
+			info.PermissionMode = next()
+		case "--dangerously-skip-permissions":
+			info.PermissionMode = "bypassPermissions"
+		case "--mcp-config":
+			info.MCPConfig = true
+		case "--output-format":
+			info.OutputFormat = next()
+			streamJSON = streamJSON || info.OutputFormat == "stream-json"
+		case "--input-format":
+			streamJSON = streamJSON || next() == "stream-json"
+		}
+	}
+
+	if printMode {
+		info.Mode = ModePrint
+		if streamJSON {
+			info.Mode = ModeHeadless
+		}
+	}
+
+	info.Args = redactArgs(args)
+	return info
+}
+
+// redactArgs hides values of sensitive flags and inline JSON, which for
+// --settings and --mcp-config may hold environment variables with tokens,
+// and shortens long arguments such as prompts
+func redactArgs(args []string) []string {
+	out := make([]string, 0, len(args))
+	hideNext := false
+	for _, arg := range args {
+		switch {
+		case hideNext:
+			arg = redacted
+			hideNext = false
+		case strings.HasPrefix(arg, "{") || strings.HasPrefix(arg, "["):
+			arg = redacted
+		case strings.HasPrefix(arg, "-"):
+			name, value, ok := strings.Cut(arg, "=")
+			switch {
+			case isSensitive(name) && ok:
+				arg = name + "=" + redacted
+			case isSensitive(name):
+				hideNext = true
+			case ok && (strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[")):
+				arg = name + "=" + redacted
+			}
+		}
+		if len(arg) > maxArgLength {
+			arg = strings.ToValidUTF8(arg[:maxArgLength], "") + "…"
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+// filterEnv keeps allowlisted variables and strips credentials and query
+// strings from URLs
+func filterEnv(environ []string) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !envAllowlist[name] || isSensitive(name) {
+			continue
+		}
+		if u, err := url.Parse(value); err == nil && u.Host != "" {
+			u.User = nil
+			u.RawQuery = ""
+			value = u.String()
+		}
+		env[name] = value
+	}
+	return env
+}
@@ -0,0 +1,149 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Netlink process connector constants from linux/connector.h and
+// linux/cn_proc.h
+const (
+	netlinkConnector = 11
+	cnIdxProc        = 1
+	cnValProc        = 1
+
+	procCnMcastListen = 1
+
+	procEventFork = 0x00000001
+	procEventExec = 0x00000002
+	procEventExit = 0x80000000
+
+	// nlmsghdr is 16 bytes, cn_msg 20 and the proc_event header 16
+	nlmsgHeaderLen   = 16
+	cnMsgLen         = 20
+	procEventHdrLen  = 16
+	connectorRcvBuf  = 1 << 20
+	connectorMaxRead = 1 << 16
+)
+
+// ProcEvent is a fork, exec or exit reported by the process connector.
+// For fork, PID is the child and ParentPID the parent. For exit, ExitCode
+// is the wait status.
+type ProcEvent struct {
+	Type       uint32
+	Timestamp  time.Time
+	PID        int
+	TGID       int
+	ParentPID  int
+	ParentTGID int
+	ExitCode   int
+}
+
+// ProcConnector receives process events from the kernel over netlink. It
+// needs CAP_NET_ADMIN and only works in the initial network namespace.
+type ProcConnector struct {
+	fd int
+}
+
+// NewProcConnector subscribes to process events
+func NewProcConnector() (*ProcConnector, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, netlinkConnector)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket: %w", err)
+	}
+	syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, connectorRcvBuf)
+
+	addr := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: cnIdxProc}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("netlink bind: %w", err)
+	}
+
+	msg := make([]byte, nlmsgHeaderLen+cnMsgLen+4)
+	binary.NativeEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:], syscall.NLMSG_DONE)
+	binary.NativeEndian.PutUint32(msg[12:], uint32(os.Getpid()))
+	cn := msg[nlmsgHeaderLen:]
+	binary.NativeEndian.PutUint32(cn[0:], cnIdxProc)
+	binary.NativeEndian.PutUint32(cn[4:], cnValProc)
+	binary.NativeEndian.PutUint16(cn[16:], 4)
+	binary.NativeEndian.PutUint32(cn[cnMsgLen:], procCnMcastListen)
+
+	if err := syscall.Sendto(fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("netlink subscribe: %w", err)
+	}
+
+	return &ProcConnector{fd: fd}, nil
+}
+
+// Run calls handle for every event until the socket fails. Events lost
+// because the receive buffer overflowed are skipped.
+func (c *ProcConnector) Run(handle func(ProcEvent)) error {
+	buf := make([]byte, connectorMaxRead)
+	for {
+		n, _, err := syscall.Recvfrom(c.fd, buf, 0)
+		if err == syscall.EINTR || err == syscall.ENOBUFS {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs {
+			if ev, ok := parseProcEvent(m.Data); ok {
+				handle(ev)
+			}
+		}
+	}
+}
+
+// Close unsubscribes
+func (c *ProcConnector) Close() error {
+	return syscall.Close(c.fd)
+}
+
+// parseProcEvent decodes a cn_msg carrying a proc_event
+func parseProcEvent(data []byte) (ProcEvent, bool) {
+	if len(data) < cnMsgLen+procEventHdrLen {
+		return ProcEvent{}, false
+	}
+	if binary.NativeEndian.Uint32(data[0:]) != cnIdxProc || binary.NativeEndian.Uint32(data[4:]) != cnValProc {
+		return ProcEvent{}, false
+	}
+
+	ev := data[cnMsgLen:]
+	e := ProcEvent{
+		Type: binary.NativeEndian.Uint32(ev[0:]),
+		// Nanoseconds since boot; the receive time is close enough
+		Timestamp: time.Now(),
+	}
+	body := ev[procEventHdrLen:]
+	u32 := func(i int) int {
+		if len(body) < (i+1)*4 {
+			return 0
+		}
+		return int(binary.NativeEndian.Uint32(body[i*4:]))
+	}
+
+	switch e.Type {
+	case procEventFork:
+		e.ParentPID, e.ParentTGID = u32(0), u32(1)
+		e.PID, e.TGID = u32(2), u32(3)
+	case procEventExec:
+		e.PID, e.TGID = u32(0), u32(1)
+	case procEventExit:
+		e.PID, e.TGID = u32(0), u32(1)
+		e.ExitCode = u32(2)
+	default:
+		return ProcEvent{}, false
+	}
+	return e, true
+}
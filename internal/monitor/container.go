@@ -0,0 +1,373 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Container runtimes
+const (
+	RuntimeDocker     = "docker"
+	RuntimePodman     = "podman"
+	RuntimeContainerd = "containerd"
+	RuntimeCRIO       = "cri-o"
+	RuntimeKubernetes = "kubernetes"
+	RuntimeLXC        = "lxc"
+)
+
+const (
+	containerAPITimeout = time.Second
+	// containerNameTTL is how long a name, or its absence, is cached
+	containerNameTTL = time.Minute
+)
+
+// ContainerInfo identifies the container a process runs in. Runtime and ID
+// are empty for PID namespaces of unknown sandboxes.
+type ContainerInfo struct {
+	Runtime string `json:"runtime,omitempty"`
+	// ID is the full container ID, or the container name for LXC
+	ID string `json:"id,omitempty"`
+	// Name is asked from the runtime socket, empty if it is unreachable
+	Name string `json:"name,omitempty"`
+	// PID is the process ID inside the container's PID namespace
+	PID int `json:"pid"`
+	// HostWorkingDir is the working directory as seen from the host when it
+	// is bind-mounted from there, as workspaces of dev containers are
+	HostWorkingDir string `json:"hostWorkingDir,omitempty"`
+}
+
+// ContainerGroup is the sessions running in one container
+type ContainerGroup struct {
+	Runtime    string             `json:"runtime"`
+	ID         string             `json:"id"`
+	Name       string             `json:"name,omitempty"`
+	Sessions   int                `json:"sessions"`
+	CPUPercent float64            `json:"cpuPercent"`
+	MemoryMB   float64            `json:"memoryMb"`
+	Processes  []ContainerProcess `json:"processes"`
+}
+
+// ContainerProcess is a session listed in a ContainerGroup
+type ContainerProcess struct {
+	ID         string `json:"id"`
+	PID        int    `json:"pid"`
+	Name       string `json:"name"`
+	WorkingDir string `json:"workingDir"`
+}
+
+type containerPattern struct {
+	runtime string
+	re      *regexp.Regexp
+}
+
+// containerPatterns find the runtime and ID in cgroup paths, e.g.
+// "0::/system.slice/docker-<id>.scope" or "12:memory:/docker/<id>". The
+// more specific runtimes come first, as Kubernetes nests them.
+var containerPatterns = []containerPattern{
+	{RuntimeDocker, regexp.MustCompile(`docker[-/]([0-9a-f]{64})`)},
+	{RuntimePodman, regexp.MustCompile(`libpod[-/]([0-9a-f]{64})`)},
+	{RuntimeContainerd, regexp.MustCompile(`cri-containerd[-:]([0-9a-f]{64})`)},
+	{RuntimeCRIO, regexp.MustCompile(`crio-([0-9a-f]{64})`)},
+	{RuntimeKubernetes, regexp.MustCompile(`kubepods.*/([0-9a-f]{64})`)},
+	{RuntimeLXC, regexp.MustCompile(`(?:lxc\.payload\.|/lxc/)([^/.]+)`)},
+}
+
+// mountPatterns find the container ID in the roots of the files runtimes
+// bind-mount into a container, for cgroup namespaces that hide the path
+var mountPatterns = []containerPattern{
+	{RuntimeDocker, regexp.MustCompile(`/docker/containers/([0-9a-f]{64})/`)},
+	{RuntimePodman, regexp.MustCompile(`/overlay-containers/([0-9a-f]{64})/`)},
+}
+
+type containerName struct {
+	name string
+	at   time.Time
+}
+
+// ContainerResolver identifies containers of processes and asks the
+// runtime for their names. ProcRoot can point at a fixture directory laid
+// out like /proc; Sockets are the Docker API compatible runtime sockets.
+type ContainerResolver struct {
+	ProcRoot string
+	Sockets  []string
+
+	mu    sync.Mutex
+	names map[string]containerName
+}
+
+// NewContainerResolver creates a resolver for the live /proc and the
+// default Docker and Podman sockets
+func NewContainerResolver() *ContainerResolver {
+	sockets := []string{"/var/run/docker.sock", "/run/podman/podman.sock"}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sockets = append(sockets, filepath.Join(dir, "podman", "podman.sock"), filepath.Join(dir, "docker.sock"))
+	}
+	return &ContainerResolver{
+		ProcRoot: "/proc",
+		Sockets:  sockets,
+		names:    make(map[string]containerName),
+	}
+}
+
+// Resolve returns the container of a process, or nil if it shares the PID
+// namespace of the monitor. cwd is the working directory as the process
+// sees it. The name is filled in by withName.
+func (c *ContainerResolver) Resolve(pid int, cwd string) *ContainerInfo {
+	dir := filepath.Join(c.ProcRoot, strconv.Itoa(pid))
+
+	// NSpid lists the PID in each nested namespace, outermost first
+	status, err := os.ReadFile(filepath.Join(dir, "status"))
+	if err != nil {
+		return nil
+	}
+	var nspids []string
+	for _, line := range strings.Split(string(status), "\n") {
+		if rest, ok := strings.CutPrefix(line, "NSpid:"); ok {
+			nspids = strings.Fields(rest)
+		}
+	}
+	if len(nspids) < 2 {
+		return nil
+	}
+
+	info := &ContainerInfo{}
+	info.PID, _ = strconv.Atoi(nspids[len(nspids)-1])
+
+	mountinfo, _ := os.ReadFile(filepath.Join(dir, "mountinfo"))
+	if cgroup, err := os.ReadFile(filepath.Join(dir, "cgroup")); err == nil {
+		info.Runtime, info.ID = matchContainer(string(cgroup), containerPatterns)
+	}
+	if info.ID == "" {
+		info.Runtime, info.ID = matchContainer(string(mountinfo), mountPatterns)
+	}
+	if cwd != "" {
+		info.HostWorkingDir = c.hostPath(parseMountinfo(string(mountinfo)), cwd)
+	}
+
+	return info
+}
+
+// withName returns a copy of info with the current name of the container
+func (c *ContainerResolver) withName(info *ContainerInfo) *ContainerInfo {
+	named := *info
+	if named.ID != "" && named.Runtime != RuntimeLXC {
+		named.Name = c.name(named.ID)
+	}
+	return &named
+}
+
+func matchContainer(content string, patterns []containerPattern) (string, string) {
+	for _, p := range patterns {
+		if m := p.re.FindStringSubmatch(content); m != nil {
+			return p.runtime, m[1]
+		}
+	}
+	return "", ""
+}
+
+type mountEntry struct {
+	device     string
+	root       string
+	mountPoint string
+	fsType     string
+}
+
+// parseMountinfo reads the lines of /proc/{pid}/mountinfo:
+// id parent major:minor root mountpoint options [optional...] - fstype source
+func parseMountinfo(content string) []mountEntry {
+	var mounts []mountEntry
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 7 {
+			continue
+		}
+		m := mountEntry{
+			device:     fields[2],
+			root:       unescapeMountPath(fields[3]),
+			mountPoint: unescapeMountPath(fields[4]),
+		}
+		for i := 6; i < len(fields)-1; i++ {
+			if fields[i] == "-" {
+				m.fsType = fields[i+1]
+				break
+			}
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
+
+// unescapeMountPath decodes the octal escapes of spaces and other
+// whitespace in mountinfo paths
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// longestMount returns the mount containing path, later mounts winning
+// over earlier ones at the same point
+func longestMount(mounts []mountEntry, path string) (mountEntry, bool) {
+	var best mountEntry
+	found := false
+	for _, m := range mounts {
+		if isWithin(path, m.mountPoint) && (!found || len(m.mountPoint) >= len(best.mountPoint)) {
+			best, found = m, true
+		}
+	}
+	return best, found
+}
+
+func isWithin(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// hostPath translates a path inside a container to the host when it lies
+// on a bind mount of a filesystem the host has mounted too. Paths on the
+// container's own root filesystem have no host path.
+func (c *ContainerResolver) hostPath(mounts []mountEntry, path string) string {
+	inner, ok := longestMount(mounts, path)
+	if !ok || inner.fsType == "overlay" || inner.mountPoint == "/" {
+		return ""
+	}
+	// Path relative to the root of the filesystem
+	fsPath := filepath.Join(inner.root, strings.TrimPrefix(path, inner.mountPoint))
+
+	hostInfo, err := os.ReadFile(filepath.Join(c.ProcRoot, "self", "mountinfo"))
+	if err != nil {
+		return ""
+	}
+	var best mountEntry
+	found := false
+	for _, m := range parseMountinfo(string(hostInfo)) {
+		if m.device == inner.device && isWithin(fsPath, m.root) && (!found || len(m.root) > len(best.root)) {
+			best, found = m, true
+		}
+	}
+	if !found {
+		return ""
+	}
+	return filepath.Join(best.mountPoint, strings.TrimPrefix(fsPath, best.root))
+}
+
+// name asks the runtime sockets for the name of a container
+func (c *ContainerResolver) name(id string) string {
+	c.mu.Lock()
+	cached, ok := c.names[id]
+	c.mu.Unlock()
+	if ok && time.Since(cached.at) < containerNameTTL {
+		return cached.name
+	}
+
+	name := ""
+	for _, socket := range c.Sockets {
+		if name = inspectContainerName(socket, id); name != "" {
+			break
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range c.names {
+		if time.Since(v.at) > containerNameTTL {
+			delete(c.names, k)
+		}
+	}
+	c.names[id] = containerName{name: name, at: time.Now()}
+	return name
+}
+
+// inspectContainerName calls GET /containers/{id}/json of the Docker
+// Engine API, which Podman serves as well
+func inspectContainerName(socket, id string) string {
+	if _, err := os.Stat(socket); err != nil {
+		return ""
+	}
+	client := &http.Client{
+		Timeout: containerAPITimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get("http://localhost/containers/" + id + "/json")
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	var container struct {
+		Name string `json:"Name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&container); err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(container.Name, "/")
+}
+
+// GroupByContainer groups the sessions running in containers, busiest
+// container first
+func GroupByContainer(processes []ClaudeProcess) []ContainerGroup {
+	byID := make(map[string]*ContainerGroup)
+	for _, p := range processes {
+		if p.Container == nil || p.Container.ID == "" {
+			continue
+		}
+		key := p.Container.Runtime + "/" + p.Container.ID
+		g, ok := byID[key]
+		if !ok {
+			g = &ContainerGroup{Runtime: p.Container.Runtime, ID: p.Container.ID, Name: p.Container.Name}
+			byID[key] = g
+		}
+		g.Sessions++
+		g.CPUPercent += p.CPUPercent
+		g.MemoryMB += p.MemoryMB
+		g.Processes = append(g.Processes, ContainerProcess{
+			ID:         p.ID,
+			PID:        p.PID,
+			Name:       p.Name,
+			WorkingDir: p.WorkingDir,
+		})
+	}
+
+	groups := make([]ContainerGroup, 0, len(byID))
+	for _, g := range byID {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].CPUPercent != groups[j].CPUPercent {
+			return groups[i].CPUPercent > groups[j].CPUPercent
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups
+}
@@ -0,0 +1,244 @@
+package monitor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// atClkTck is the auxiliary vector entry holding sysconf(_SC_CLK_TCK)
+const atClkTck = 17
+
+// readClockTicks returns the kernel USER_HZ as passed to this process in
+// its auxiliary vector, which is what sysconf(_SC_CLK_TCK) reports
+func readClockTicks() float64 {
+	data, err := os.ReadFile("/proc/self/auxv")
+	if err != nil {
+		return 100
+	}
+
+	// auxv is a list of (type, value) pairs of native word size
+	wordSize := strconv.IntSize / 8
+	for i := 0; i+2*wordSize <= len(data); i += 2 * wordSize {
+		var key, val uint64
+		if wordSize == 8 {
+			key = binary.NativeEndian.Uint64(data[i:])
+			val = binary.NativeEndian.Uint64(data[i+wordSize:])
+		} else {
+			key = uint64(binary.NativeEndian.Uint32(data[i:]))
+			val = uint64(binary.NativeEndian.Uint32(data[i+wordSize:]))
+		}
+		if key == atClkTck && val > 0 {
+			return float64(val)
+		}
+		if key == 0 {
+			break
+		}
+	}
+
+	return 100
+}
+
+// readBootTime returns the boot time in seconds since the epoch from the
+// btime line of /proc/stat
+func readBootTime(procRoot string) (int64, error) {
+	f, err := os.Open(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "btime" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+
+	return 0, fmt.Errorf("btime not found in %s/stat", procRoot)
+}
+
+// readSystemCPUTicks returns the busy and total clock ticks of all CPUs
+// from the aggregate "cpu" line of /proc/stat
+func readSystemCPUTicks(procRoot string) (busy, total uint64, err error) {
+	f, err := os.Open(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		busy, total = cpuLineTicks(fields[1:])
+		return busy, total, nil
+	}
+
+	return 0, 0, fmt.Errorf("cpu line not found in %s/stat", procRoot)
+}
+
+// cpuLineTicks sums the columns of a /proc/stat cpu line: user nice system
+// idle iowait irq softirq steal guest guest_nice. Guest time is already
+// included in user and nice.
+func cpuLineTicks(values []string) (busy, total uint64) {
+	for i, v := range values {
+		if i >= 8 {
+			break
+		}
+		n, _ := strconv.ParseUint(v, 10, 64)
+		total += n
+		if i != 3 && i != 4 { // idle, iowait
+			busy += n
+		}
+	}
+	return busy, total
+}
+
+// parseCPUList parses kernel CPU lists like "0-3,6,8-11" and returns the
+// number of CPUs they contain
+func parseCPUList(s string) (int, error) {
+	count := 0
+	for _, part := range strings.Split(strings.TrimSpace(s), ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return 0, err
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil {
+				return 0, err
+			}
+		}
+		if end < start {
+			return 0, fmt.Errorf("invalid CPU range %q", part)
+		}
+		count += end - start + 1
+	}
+	return count, nil
+}
+
+// onlineCPUs returns the number of online CPUs
+func onlineCPUs() int {
+	data, err := os.ReadFile("/sys/devices/system/cpu/online")
+	if err == nil {
+		if n, err := parseCPUList(string(data)); err == nil && n > 0 {
+			return n
+		}
+	}
+	return runtime.NumCPU()
+}
+
+// cgroupCPULimit returns the CPU quota of the cgroup of pid in cores, or
+// 0 if it is not limited. The tightest limit on the path to the root of
+// the hierarchy applies.
+func cgroupCPULimit(pid int) float64 {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return 0
+	}
+
+	limit := 0.0
+	tighten := func(l float64) {
+		if l > 0 && (limit == 0 || l < limit) {
+			limit = l
+		}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// hierarchy-ID:controllers:path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			// cgroup v2
+			walkCgroup("/sys/fs/cgroup", parts[2], func(dir string) {
+				tighten(readCPUMax(filepath.Join(dir, "cpu.max")))
+			})
+			continue
+		}
+		if !slices.Contains(strings.Split(parts[1], ","), "cpu") {
+			continue
+		}
+		// cgroup v1, mounted per controller list or as "cpu"
+		for _, mount := range []string{filepath.Join("/sys/fs/cgroup", parts[1]), "/sys/fs/cgroup/cpu"} {
+			if _, err := os.Stat(mount); err != nil {
+				continue
+			}
+			walkCgroup(mount, parts[2], func(dir string) {
+				quota := readInt(filepath.Join(dir, "cpu.cfs_quota_us"))
+				period := readInt(filepath.Join(dir, "cpu.cfs_period_us"))
+				if quota > 0 && period > 0 {
+					tighten(float64(quota) / float64(period))
+				}
+			})
+			break
+		}
+	}
+	return limit
+}
+
+// walkCgroup calls fn for the cgroup at path below mount and each of its
+// ancestors up to mount
+func walkCgroup(mount, path string, fn func(dir string)) {
+	for dir := filepath.Join(mount, path); ; dir = filepath.Dir(dir) {
+		fn(dir)
+		if dir == mount || dir == "/" {
+			break
+		}
+	}
+}
+
+// readCPUMax parses a cgroup v2 cpu.max file ("$MAX $PERIOD" or "max $PERIOD")
+func readCPUMax(path string) float64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || period <= 0 {
+		return 0
+	}
+	return quota / period
+}
+
+func readInt(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	val, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return val
+}
+
+// availableCPUs returns the number of cores the machine offers to pid: the
+// online CPUs, capped by the quota of its cgroup if there is one
+func availableCPUs(pid int) float64 {
+	cores := float64(onlineCPUs())
+	if limit := cgroupCPULimit(pid); limit > 0 {
+		cores = math.Min(cores, limit)
+	}
+	return cores
+}
@@ -0,0 +1,157 @@
+package monitor
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CoreFrequency is the frequency scaling state of one logical CPU
+type CoreFrequency struct {
+	CPU                  int     `json:"cpu"`
+	MHz                  float64 `json:"mhz"`
+	MinMHz               float64 `json:"minMhz,omitempty"`
+	MaxMHz               float64 `json:"maxMhz,omitempty"`
+	Governor             string  `json:"governor,omitempty"`
+	CoreThrottleCount    uint64  `json:"coreThrottleCount"`
+	PackageThrottleCount uint64  `json:"packageThrottleCount"`
+}
+
+// CPUFreqStatus summarizes CPU frequencies and thermal throttling
+type CPUFreqStatus struct {
+	Timestamp int64           `json:"timestamp"`
+	Cores     []CoreFrequency `json:"cores"`
+	AvgMHz    float64         `json:"avgMhz"`
+	MaxMHz    float64         `json:"maxMhz"`
+	Governors []string        `json:"governors"`
+	// Throttling is true when a throttle counter increased since the
+	// previous sample
+	Throttling            bool   `json:"throttling"`
+	CoreThrottleEvents    uint64 `json:"coreThrottleEvents"`
+	PackageThrottleEvents uint64 `json:"packageThrottleEvents"`
+	CoreThrottleTotal     uint64 `json:"coreThrottleTotal"`
+	PackageThrottleTotal  uint64 `json:"packageThrottleTotal"`
+}
+
+// CPUFreqMonitor reads cpufreq and thermal_throttle counters from
+// /sys/devices/system/cpu
+type CPUFreqMonitor struct {
+	mu          sync.Mutex
+	sysfsRoot   string
+	prevCore    uint64
+	prevPackage uint64
+	hasPrev     bool
+	last        CPUFreqStatus
+}
+
+// NewCPUFreqMonitor creates a monitor reading the live /sys
+func NewCPUFreqMonitor() *CPUFreqMonitor {
+	return &CPUFreqMonitor{sysfsRoot: "/sys"}
+}
+
+// SetSysfsRoot changes the directory used in place of /sys
+func (m *CPUFreqMonitor) SetSysfsRoot(root string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sysfsRoot = root
+	m.hasPrev = false
+}
+
+var cpuDirPattern = regexp.MustCompile(`^cpu(\d+)$`)
+
+// Sample reads the current frequencies and throttle counters
+func (m *CPUFreqMonitor) Sample() CPUFreqStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := CPUFreqStatus{
+		Timestamp: time.Now().Unix(),
+		Cores:     []CoreFrequency{},
+		Governors: []string{},
+	}
+
+	dirs, _ := filepath.Glob(filepath.Join(m.sysfsRoot, "devices", "system", "cpu", "cpu*"))
+	governors := make(map[string]bool)
+	packageCounts := make(map[string]uint64)
+	var totalMHz float64
+
+	for _, dir := range dirs {
+		match := cpuDirPattern.FindStringSubmatch(filepath.Base(dir))
+		if match == nil {
+			continue
+		}
+		cpu, _ := strconv.Atoi(match[1])
+
+		// Frequencies are in kHz
+		core := CoreFrequency{CPU: cpu}
+		freq := filepath.Join(dir, "cpufreq")
+		core.MHz = float64(readInt(filepath.Join(freq, "scaling_cur_freq"))) / 1000
+		core.MinMHz = float64(readInt(filepath.Join(freq, "scaling_min_freq"))) / 1000
+		core.MaxMHz = float64(readInt(filepath.Join(freq, "scaling_max_freq"))) / 1000
+		core.Governor = readTrimmed(filepath.Join(freq, "scaling_governor"))
+
+		throttle := filepath.Join(dir, "thermal_throttle")
+		core.CoreThrottleCount = uint64(readInt(filepath.Join(throttle, "core_throttle_count")))
+		core.PackageThrottleCount = uint64(readInt(filepath.Join(throttle, "package_throttle_count")))
+
+		if core.MHz == 0 && core.Governor == "" && core.CoreThrottleCount == 0 && core.PackageThrottleCount == 0 {
+			continue // Offline CPU or no cpufreq driver
+		}
+
+		// Every CPU of a package reports the same package counter
+		pkg := readTrimmed(filepath.Join(dir, "topology", "physical_package_id"))
+		packageCounts[pkg] = core.PackageThrottleCount
+
+		status.CoreThrottleTotal += core.CoreThrottleCount
+		totalMHz += core.MHz
+		if core.MHz > status.MaxMHz {
+			status.MaxMHz = core.MHz
+		}
+		if core.Governor != "" {
+			governors[core.Governor] = true
+		}
+
+		status.Cores = append(status.Cores, core)
+	}
+
+	for _, count := range packageCounts {
+		status.PackageThrottleTotal += count
+	}
+
+	sort.Slice(status.Cores, func(i, j int) bool {
+		return status.Cores[i].CPU < status.Cores[j].CPU
+	})
+	if len(status.Cores) > 0 {
+		status.AvgMHz = totalMHz / float64(len(status.Cores))
+	}
+	for g := range governors {
+		status.Governors = append(status.Governors, g)
+	}
+	sort.Strings(status.Governors)
+
+	if m.hasPrev {
+		if status.CoreThrottleTotal > m.prevCore {
+			status.CoreThrottleEvents = status.CoreThrottleTotal - m.prevCore
+		}
+		if status.PackageThrottleTotal > m.prevPackage {
+			status.PackageThrottleEvents = status.PackageThrottleTotal - m.prevPackage
+		}
+		status.Throttling = status.CoreThrottleEvents > 0 || status.PackageThrottleEvents > 0
+	}
+	m.prevCore = status.CoreThrottleTotal
+	m.prevPackage = status.PackageThrottleTotal
+	m.hasPrev = true
+
+	m.last = status
+	return status
+}
+
+// Last returns the result of the most recent sample
+func (m *CPUFreqMonitor) Last() CPUFreqStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
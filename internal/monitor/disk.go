@@ -0,0 +1,273 @@
+package monitor
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DiskUsage is the free space of the filesystem holding a session working
+// directory, and the size of the directory if it has been scanned
+type DiskUsage struct {
+	Path string `json:"path"`
+	PIDs []int  `json:"pids"`
+	// Device identifies the filesystem; directories on the same device
+	// share its free space
+	Device      uint64  `json:"device"`
+	TotalMB     float64 `json:"totalMb"`
+	FreeMB      float64 `json:"freeMb"`
+	AvailableMB float64 `json:"availableMb"`
+	UsedPercent float64 `json:"usedPercent"`
+	// FreePercent is the space available to unprivileged users
+	FreePercent float64 `json:"freePercent"`
+	Error       string  `json:"error,omitempty"`
+
+	Scan *DirScan `json:"scan,omitempty"`
+}
+
+// DirScan is the result of a background size scan of a directory
+type DirScan struct {
+	SizeMB    float64 `json:"sizeMb"`
+	Files     int     `json:"files"`
+	Dirs      int     `json:"dirs"`
+	Skipped   int     `json:"skipped"`
+	StartedAt int64   `json:"startedAt"`
+	Duration  float64 `json:"durationSeconds"`
+	Running   bool    `json:"running"`
+}
+
+// DiskScanConfig controls the background directory size scan
+type DiskScanConfig struct {
+	Enabled bool `json:"enabled"`
+	// IntervalMinutes is the minimum time between scans of a directory
+	IntervalMinutes float64 `json:"intervalMinutes"`
+	// EntriesPerSecond caps the rate at which the scan reads directory
+	// entries, so that it does not compete with the sessions for I/O
+	EntriesPerSecond int `json:"entriesPerSecond"`
+	// Excludes are base name patterns (filepath.Match) of entries that
+	// are not scanned, e.g. ".git" or "node_modules"
+	Excludes []string `json:"excludes"`
+}
+
+// DefaultDiskScanConfig returns a disabled scan with conservative limits
+func DefaultDiskScanConfig() DiskScanConfig {
+	return DiskScanConfig{
+		IntervalMinutes:  30,
+		EntriesPerSecond: 2000,
+		Excludes:         []string{".git"},
+	}
+}
+
+// DiskMonitor reports free space per working directory and runs at most
+// one background size scan at a time
+type DiskMonitor struct {
+	mu       sync.Mutex
+	config   DiskScanConfig
+	scans    map[string]*DirScan
+	scanning bool
+	last     []DiskUsage
+}
+
+// NewDiskMonitor creates a monitor with the scan disabled
+func NewDiskMonitor() *DiskMonitor {
+	return &DiskMonitor{
+		config: DefaultDiskScanConfig(),
+		scans:  make(map[string]*DirScan),
+	}
+}
+
+// SetScanConfig changes the background scan settings. Results of
+// previous scans are kept.
+func (m *DiskMonitor) SetScanConfig(config DiskScanConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+}
+
+// Sample runs statfs on the working directory of every process and starts
+// a background scan of the directory scanned least recently, if one is due
+func (m *DiskMonitor) Sample(processes []ClaudeProcess) []DiskUsage {
+	pids := make(map[string][]int)
+	for _, p := range processes {
+		if p.WorkingDir != "" {
+			pids[p.WorkingDir] = append(pids[p.WorkingDir], p.PID)
+		}
+	}
+
+	usages := []DiskUsage{}
+	for dir, list := range pids {
+		u := statDisk(dir)
+		u.PIDs = list
+		usages = append(usages, u)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Path < usages[j].Path
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range usages {
+		if scan, ok := m.scans[usages[i].Path]; ok {
+			copied := *scan
+			usages[i].Scan = &copied
+		}
+	}
+	// Forget directories no session uses anymore
+	for dir, scan := range m.scans {
+		if _, ok := pids[dir]; !ok && !scan.Running {
+			delete(m.scans, dir)
+		}
+	}
+
+	if m.config.Enabled && !m.scanning {
+		if dir := m.nextScan(usages); dir != "" {
+			m.scanning = true
+			m.scans[dir] = &DirScan{StartedAt: time.Now().Unix(), Running: true}
+			go m.scan(dir, m.config)
+		}
+	}
+
+	m.last = usages
+	return usages
+}
+
+// Last returns the result of the most recent sample
+func (m *DiskMonitor) Last() []DiskUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// nextScan returns the directory whose scan is most overdue, or "" if no
+// scan is due
+func (m *DiskMonitor) nextScan(usages []DiskUsage) string {
+	interval := time.Duration(m.config.IntervalMinutes * float64(time.Minute))
+	now := time.Now()
+
+	next := ""
+	var oldest int64
+	for _, u := range usages {
+		if u.Error != "" {
+			continue
+		}
+		scan, ok := m.scans[u.Path]
+		if !ok {
+			return u.Path
+		}
+		if now.Sub(time.Unix(scan.StartedAt, 0)) < interval {
+			continue
+		}
+		if next == "" || scan.StartedAt < oldest {
+			next, oldest = u.Path, scan.StartedAt
+		}
+	}
+	return next
+}
+
+// scan walks dir without crossing filesystems or following symlinks,
+// sleeping as needed to stay below config.EntriesPerSecond
+func (m *DiskMonitor) scan(dir string, config DiskScanConfig) {
+	start := time.Now()
+	result := DirScan{StartedAt: start.Unix()}
+
+	var rootDev uint64
+	if st, err := lstat(dir); err == nil {
+		rootDev = uint64(st.Dev)
+	}
+
+	var bytes int64
+	entries := 0
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			result.Skipped++
+			return nil
+		}
+
+		entries++
+		if config.EntriesPerSecond > 0 {
+			if ahead := time.Duration(entries)*time.Second/time.Duration(config.EntriesPerSecond) - time.Since(start); ahead > 0 {
+				time.Sleep(ahead)
+			}
+		}
+
+		if path != dir && excluded(d.Name(), config.Excludes) {
+			result.Skipped++
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		st, err := lstat(path)
+		if err != nil {
+			result.Skipped++
+			return nil
+		}
+		if d.IsDir() {
+			if uint64(st.Dev) != rootDev {
+				result.Skipped++
+				return filepath.SkipDir
+			}
+			result.Dirs++
+		} else {
+			result.Files++
+		}
+		// Allocated blocks, so sparse files count what they use
+		bytes += st.Blocks * 512
+		return nil
+	})
+
+	result.SizeMB = float64(bytes) / (1024 * 1024)
+	result.Duration = time.Since(start).Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scans[dir] = &result
+	m.scanning = false
+}
+
+func excluded(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func lstat(path string) (*syscall.Stat_t, error) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// statDisk reads the filesystem statistics of dir
+func statDisk(dir string) DiskUsage {
+	u := DiskUsage{Path: dir}
+
+	var fsStat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fsStat); err != nil {
+		u.Error = err.Error()
+		return u
+	}
+	if st, err := lstat(dir); err == nil {
+		u.Device = uint64(st.Dev)
+	}
+
+	blockMB := float64(fsStat.Bsize) / (1024 * 1024)
+	u.TotalMB = float64(fsStat.Blocks) * blockMB
+	u.FreeMB = float64(fsStat.Bfree) * blockMB
+	u.AvailableMB = float64(fsStat.Bavail) * blockMB
+	if u.TotalMB > 0 {
+		u.UsedPercent = (u.TotalMB - u.FreeMB) / u.TotalMB * 100
+		u.FreePercent = u.AvailableMB / u.TotalMB * 100
+	}
+
+	return u
+}
@@ -0,0 +1,302 @@
+package monitor
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RAPLDomain is a power domain of the CPU (package, core, uncore, dram)
+type RAPLDomain struct {
+	Name   string  `json:"name"`
+	Joules float64 `json:"joules"`
+	Watts  float64 `json:"watts"`
+}
+
+// SessionEnergy is the package energy attributed to one Claude process tree
+type SessionEnergy struct {
+	PID        int     `json:"pid"`
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	WorkingDir string  `json:"workingDir"`
+	Joules     float64 `json:"joules"`
+	Watts      float64 `json:"watts"`
+}
+
+// ProjectEnergy is the package energy attributed to all sessions in a
+// working directory, including sessions that have exited
+type ProjectEnergy struct {
+	Project string  `json:"project"`
+	Joules  float64 `json:"joules"`
+	Watts   float64 `json:"watts"`
+}
+
+// EnergyStatus is the result of an energy sample
+type EnergyStatus struct {
+	Available    bool            `json:"available"`
+	Timestamp    int64           `json:"timestamp"`
+	PackageWatts float64         `json:"packageWatts"`
+	CoreWatts    float64         `json:"coreWatts"`
+	DRAMWatts    float64         `json:"dramWatts"`
+	Domains      []RAPLDomain    `json:"domains"`
+	Sessions     []SessionEnergy `json:"sessions"`
+	Projects     []ProjectEnergy `json:"projects"`
+}
+
+// raplZone is a powercap zone directory
+type raplZone struct {
+	name     string
+	dir      string
+	maxRange uint64
+}
+
+// RAPLCollector reads cumulative energy counters from
+// /sys/class/powercap/intel-rapl*. SysfsRoot can point at a fixture
+// directory laid out like /sys.
+type RAPLCollector struct {
+	SysfsRoot string
+}
+
+// NewRAPLCollector creates a collector reading the live /sys
+func NewRAPLCollector() *RAPLCollector {
+	return &RAPLCollector{SysfsRoot: "/sys"}
+}
+
+// zones returns all RAPL zones. Subzones are named after their package,
+// e.g. "package-0/core".
+func (c *RAPLCollector) zones() []raplZone {
+	dirs, err := filepath.Glob(filepath.Join(c.SysfsRoot, "class", "powercap", "intel-rapl:*"))
+	if err != nil {
+		return nil
+	}
+
+	names := make(map[string]string)
+	for _, dir := range dirs {
+		names[filepath.Base(dir)] = readTrimmed(filepath.Join(dir, "name"))
+	}
+
+	var zones []raplZone
+	for _, dir := range dirs {
+		id := filepath.Base(dir)
+		name := names[id]
+		if name == "" {
+			continue
+		}
+		// intel-rapl:0:1 is a subzone of intel-rapl:0
+		if parts := strings.Split(id, ":"); len(parts) == 3 {
+			name = names[parts[0]+":"+parts[1]] + "/" + name
+		}
+		maxRange, _ := strconv.ParseUint(readTrimmed(filepath.Join(dir, "max_energy_range_uj")), 10, 64)
+		zones = append(zones, raplZone{name: name, dir: dir, maxRange: maxRange})
+	}
+
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].name < zones[j].name
+	})
+
+	return zones
+}
+
+// readEnergy returns energy_uj of a zone. The file is only readable by
+// root on most kernels.
+func (z raplZone) readEnergy() (uint64, bool) {
+	val, err := strconv.ParseUint(readTrimmed(filepath.Join(z.dir, "energy_uj")), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return val, true
+}
+
+// energyDelta returns the energy consumed between two counter readings,
+// accounting for the counter wrapping at maxRange
+func energyDelta(cur, prev, maxRange uint64) uint64 {
+	if cur >= prev {
+		return cur - prev
+	}
+	if maxRange == 0 || prev > maxRange {
+		return 0
+	}
+	return maxRange - prev + cur
+}
+
+// EnergyMonitor samples RAPL energy counters and apportions package energy
+// to Claude process trees by their share of the machine's busy CPU time
+type EnergyMonitor struct {
+	mu         sync.Mutex
+	rapl       *RAPLCollector
+	procRoot   string
+	clkTck     float64
+	prevSample time.Time
+	prevEnergy map[string]uint64
+	prevBusy   uint64
+	prevCPU    map[string]float64
+	domainJ    map[string]float64
+	sessionJ   map[string]float64
+	projectJ   map[string]float64
+	last       EnergyStatus
+}
+
+// NewEnergyMonitor creates a new energy monitor
+func NewEnergyMonitor() *EnergyMonitor {
+	return &EnergyMonitor{
+		rapl:       NewRAPLCollector(),
+		procRoot:   "/proc",
+		clkTck:     readClockTicks(),
+		prevEnergy: make(map[string]uint64),
+		prevCPU:    make(map[string]float64),
+		domainJ:    make(map[string]float64),
+		sessionJ:   make(map[string]float64),
+		projectJ:   make(map[string]float64),
+	}
+}
+
+// SetSysfsRoot changes the directory used in place of /sys
+func (em *EnergyMonitor) SetSysfsRoot(root string) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.rapl.SysfsRoot = root
+}
+
+// Sample reads the energy counters, attributes the energy consumed since
+// the previous sample to the given processes and fills in their
+// EnergyJoules and PowerWatts
+func (em *EnergyMonitor) Sample(processes []ClaudeProcess) EnergyStatus {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(em.prevSample).Seconds()
+	first := em.prevSample.IsZero()
+
+	status := EnergyStatus{
+		Timestamp: now.Unix(),
+		Domains:   []RAPLDomain{},
+		Sessions:  []SessionEnergy{},
+		Projects:  []ProjectEnergy{},
+	}
+
+	// Energy per domain since the previous sample
+	var packageJ float64
+	for _, zone := range em.rapl.zones() {
+		energy, ok := zone.readEnergy()
+		if !ok {
+			continue
+		}
+		status.Available = true
+
+		var joules float64
+		if prev, ok := em.prevEnergy[zone.dir]; ok {
+			joules = float64(energyDelta(energy, prev, zone.maxRange)) / 1e6
+		}
+		em.prevEnergy[zone.dir] = energy
+		em.domainJ[zone.name] += joules
+
+		domain := RAPLDomain{Name: zone.name, Joules: em.domainJ[zone.name]}
+		if !first && elapsed > 0 {
+			domain.Watts = joules / elapsed
+		}
+		status.Domains = append(status.Domains, domain)
+
+		switch {
+		case strings.HasPrefix(zone.name, "package-") && !strings.Contains(zone.name, "/"):
+			packageJ += joules
+			status.PackageWatts += domain.Watts
+		case strings.HasSuffix(zone.name, "/core"):
+			status.CoreWatts += domain.Watts
+		case strings.HasSuffix(zone.name, "/dram"):
+			status.DRAMWatts += domain.Watts
+		}
+	}
+
+	// Busy CPU time of the whole machine since the previous sample
+	busy, _, err := readSystemCPUTicks(em.procRoot)
+	var busyDelta float64
+	if err == nil && busy > em.prevBusy && !first {
+		busyDelta = float64(busy-em.prevBusy) / em.clkTck
+	}
+	em.prevBusy = busy
+
+	// Attribute package energy by share of busy CPU time
+	currentCPU := make(map[string]float64)
+	projectW := make(map[string]float64)
+	for i := range processes {
+		p := &processes[i]
+		currentCPU[p.ID] = p.CPUSeconds
+
+		var joules float64
+		if prev, ok := em.prevCPU[p.ID]; ok && busyDelta > 0 && p.CPUSeconds > prev {
+			share := (p.CPUSeconds - prev) / busyDelta
+			if share > 1 {
+				share = 1
+			}
+			joules = packageJ * share
+		}
+		em.sessionJ[p.ID] += joules
+		em.projectJ[p.WorkingDir] += joules
+
+		p.EnergyJoules = em.sessionJ[p.ID]
+		if !first && elapsed > 0 {
+			p.PowerWatts = joules / elapsed
+		}
+		projectW[p.WorkingDir] += p.PowerWatts
+
+		status.Sessions = append(status.Sessions, SessionEnergy{
+			PID:        p.PID,
+			ID:         p.ID,
+			Name:       p.Name,
+			WorkingDir: p.WorkingDir,
+			Joules:     p.EnergyJoules,
+			Watts:      p.PowerWatts,
+		})
+	}
+
+	// Forget sessions that have exited; their energy stays in the project
+	for id := range em.sessionJ {
+		if _, ok := currentCPU[id]; !ok {
+			delete(em.sessionJ, id)
+		}
+	}
+	em.prevCPU = currentCPU
+	em.prevSample = now
+
+	for project, joules := range em.projectJ {
+		status.Projects = append(status.Projects, ProjectEnergy{
+			Project: project,
+			Joules:  joules,
+			Watts:   projectW[project],
+		})
+	}
+	sort.Slice(status.Projects, func(i, j int) bool {
+		return status.Projects[i].Joules > status.Projects[j].Joules
+	})
+
+	em.last = status
+	return status
+}
+
+// Last returns the result of the most recent sample
+func (em *EnergyMonitor) Last() EnergyStatus {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.last
+}
+
+// Annotate fills in EnergyJoules and PowerWatts of processes from the most
+// recent sample without taking a new one
+func (em *EnergyMonitor) Annotate(processes []ClaudeProcess) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	for i := range processes {
+		for _, s := range em.last.Sessions {
+			if s.ID == processes[i].ID {
+				processes[i].EnergyJoules = s.Joules
+				processes[i].PowerWatts = s.Watts
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package monitor
+
+import (
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Event types
+const (
+	EventStart = "start"
+	EventExit  = "exit"
+)
+
+// Sources of an ExitStatus
+const (
+	// ExitSourceZombie is the exit code read from a zombie's stat
+	ExitSourceZombie = "zombie"
+	// ExitSourceNetlink is the status reported by the process connector
+	ExitSourceNetlink = "netlink"
+	// ExitSourceWait is the status returned by waitid on a pidfd, only
+	// available for children of the monitor
+	ExitSourceWait = "wait"
+	// ExitSourceMonitor means the monitor signalled the process shortly
+	// before it exited; the actual status was not observed
+	ExitSourceMonitor = "monitor"
+)
+
+const (
+	maxEvents     = 1000
+	maxSessions   = 200
+	signalNoteTTL = 30 * time.Second
+	// scanGraceSeconds is how long a session may be missing from scans
+	// right after it started
+	scanGraceSeconds = 10
+)
+
+// ExitStatus is how a process ended
+type ExitStatus struct {
+	Code       *int   `json:"code,omitempty"`
+	Signal     string `json:"signal,omitempty"`
+	CoreDumped bool   `json:"coreDumped,omitempty"`
+	Source     string `json:"source"`
+}
+
+// SessionSummary is the lifetime and resource totals of a process
+type SessionSummary struct {
+	ID         string `json:"id"`
+	PID        int    `json:"pid"`
+	Name       string `json:"name"`
+	WorkingDir string `json:"workingDir"`
+	UID        int    `json:"uid"`
+	User       string `json:"user"`
+	StartTime  int64  `json:"startTime"`
+	// EndTime is 0 while the process is running
+	EndTime         int64   `json:"endTime,omitempty"`
+	Running         bool    `json:"running"`
+	LifetimeSeconds float64 `json:"lifetimeSeconds"`
+	PeakCPUPercent  float64 `json:"peakCpuPercent"`
+	PeakMemoryMB    float64 `json:"peakMemoryMb"`
+	CPUSeconds      float64 `json:"cpuSeconds"`
+	// SpawnedProcesses counts the processes forked by the session and its
+	// descendants, only while the process connector is running
+	SpawnedProcesses int         `json:"spawnedProcesses"`
+	Exit             *ExitStatus `json:"exit,omitempty"`
+}
+
+// ProcessEvent is a start or exit transition of a Claude process
+type ProcessEvent struct {
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	SessionSummary
+}
+
+// EventFilter selects events; zero fields match everything
+type EventFilter struct {
+	Type  string
+	ID    string
+	Since int64
+	Limit int
+}
+
+type signalNote struct {
+	signal syscall.Signal
+	at     time.Time
+}
+
+type exitNote struct {
+	at     time.Time
+	status *ExitStatus
+}
+
+// EventLog detects process start and exit between scans and keeps the
+// most recent events and finished sessions in memory
+type EventLog struct {
+	mu       sync.Mutex
+	events   []ProcessEvent
+	live     map[string]*SessionSummary
+	finished []SessionSummary
+	exits    map[string]exitNote
+	signals  map[int]signalNote
+	watcher  *exitWatcher
+}
+
+// NewEventLog creates an empty log that watches processes with pidfds
+// when the kernel supports them
+func NewEventLog() *EventLog {
+	l := &EventLog{
+		live:    make(map[string]*SessionSummary),
+		exits:   make(map[string]exitNote),
+		signals: make(map[int]signalNote),
+	}
+	l.watcher = newExitWatcher(l.recordExit)
+	return l
+}
+
+// NoteSignal records that the monitor sent sig to pid, which explains an
+// exit observed soon after
+func (l *EventLog) NoteSignal(pid int, sig syscall.Signal) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.signals[pid] = signalNote{signal: sig, at: time.Now()}
+}
+
+// recordExit is called by the exit watcher when a pidfd becomes readable
+func (l *EventLog) recordExit(id string, at time.Time, status *ExitStatus) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if note, ok := l.exits[id]; ok && note.status != nil && status == nil {
+		status = note.status
+	}
+	l.exits[id] = exitNote{at: at, status: status}
+}
+
+// observe compares a scan with the previous one. Zombies are processes
+// that have exited but not been reaped, with their exit code.
+func (l *EventLog) observe(now time.Time, processes []ClaudeProcess, zombies map[string]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := make(map[string]bool, len(processes))
+	for _, p := range processes {
+		current[p.ID] = true
+
+		s, ok := l.live[p.ID]
+		if !ok {
+			s = l.startLocked(p)
+		}
+		s.Name = p.Name
+		s.CPUSeconds = p.CPUSeconds
+		s.LifetimeSeconds = float64(now.Unix() - s.StartTime)
+		if p.CPUPercent > s.PeakCPUPercent {
+			s.PeakCPUPercent = p.CPUPercent
+		}
+		if p.MemoryMB > s.PeakMemoryMB {
+			s.PeakMemoryMB = p.MemoryMB
+		}
+	}
+
+	for id, code := range zombies {
+		note, ok := l.exits[id]
+		if !ok {
+			note.at = now // The pidfd watcher knows the exact time
+		}
+		if note.status == nil {
+			note.status = waitStatus(code, ExitSourceZombie)
+		}
+		l.exits[id] = note
+	}
+
+	for id, s := range l.live {
+		if current[id] {
+			continue
+		}
+		// Sessions reported by the process connector may have started
+		// after the scan listed /proc
+		if now.Unix()-s.StartTime < scanGraceSeconds {
+			if st, err := readProcStat("/proc", s.PID); err == nil && st.state != 'Z' && sameProcess(id, st) {
+				continue
+			}
+		}
+		l.finish(now, s)
+	}
+
+	for pid, note := range l.signals {
+		if now.Sub(note.at) > signalNoteTTL {
+			delete(l.signals, pid)
+		}
+	}
+}
+
+// start records a session reported by the process connector before the
+// next scan sees it
+func (l *EventLog) start(p ClaudeProcess) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.live[p.ID]; !ok {
+		l.startLocked(p)
+	}
+}
+
+func (l *EventLog) startLocked(p ClaudeProcess) *SessionSummary {
+	s := &SessionSummary{
+		ID:         p.ID,
+		PID:        p.PID,
+		Name:       p.Name,
+		WorkingDir: p.WorkingDir,
+		UID:        p.UID,
+		User:       p.User,
+		StartTime:  p.StartTime,
+		Running:    true,
+	}
+	l.live[p.ID] = s
+	l.watcher.watch(p.ID, p.PID)
+	l.addEvent(ProcessEvent{Type: EventStart, Timestamp: s.StartTime, SessionSummary: *s})
+	return s
+}
+
+// exit finishes a session as soon as the process connector reports it
+func (l *EventLog) exit(id string, at time.Time, status *ExitStatus) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s, ok := l.live[id]; ok {
+		l.exits[id] = exitNote{at: at, status: status}
+		l.finish(at, s)
+	}
+}
+
+// noteSpawn counts a process forked within a session
+func (l *EventLog) noteSpawn(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s, ok := l.live[id]; ok {
+		s.SpawnedProcesses++
+	}
+}
+
+// finish moves a session that is gone from the scan to the finished list
+func (l *EventLog) finish(now time.Time, s *SessionSummary) {
+	delete(l.live, s.ID)
+	l.watcher.forget(s.ID)
+
+	s.Running = false
+	s.EndTime = now.Unix()
+	if note, ok := l.exits[s.ID]; ok {
+		s.EndTime = note.at.Unix()
+		s.Exit = note.status
+		delete(l.exits, s.ID)
+	}
+	if s.Exit == nil {
+		if note, ok := l.signals[s.PID]; ok {
+			s.Exit = &ExitStatus{Signal: signalName(note.signal), Source: ExitSourceMonitor}
+		}
+	}
+	delete(l.signals, s.PID)
+	s.LifetimeSeconds = float64(s.EndTime - s.StartTime)
+
+	l.addEvent(ProcessEvent{Type: EventExit, Timestamp: s.EndTime, SessionSummary: *s})
+
+	l.finished = append(l.finished, *s)
+	if len(l.finished) > maxSessions {
+		l.finished = l.finished[len(l.finished)-maxSessions:]
+	}
+}
+
+func (l *EventLog) addEvent(e ProcessEvent) {
+	l.events = append(l.events, e)
+	if len(l.events) > maxEvents {
+		l.events = l.events[len(l.events)-maxEvents:]
+	}
+}
+
+// Events returns matching events, newest first
+func (l *EventLog) Events(filter EventFilter) []ProcessEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := []ProcessEvent{}
+	for i := len(l.events) - 1; i >= 0; i-- {
+		e := l.events[i]
+		if (filter.Type != "" && e.Type != filter.Type) ||
+			(filter.ID != "" && e.ID != filter.ID) ||
+			e.Timestamp < filter.Since {
+			continue
+		}
+		result = append(result, e)
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// Sessions returns running sessions followed by finished ones, each
+// newest first
+func (l *EventLog) Sessions() []SessionSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sessions := make([]SessionSummary, 0, len(l.live)+len(l.finished))
+	for _, s := range l.live {
+		sessions = append(sessions, *s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartTime > sessions[j].StartTime
+	})
+	for i := len(l.finished) - 1; i >= 0; i-- {
+		sessions = append(sessions, l.finished[i])
+	}
+	return sessions
+}
+
+// waitStatus decodes a wait(2) status word
+func waitStatus(status int, source string) *ExitStatus {
+	ws := syscall.WaitStatus(status)
+	exit := &ExitStatus{Source: source}
+	switch {
+	case ws.Exited():
+		code := ws.ExitStatus()
+		exit.Code = &code
+	case ws.Signaled():
+		exit.Signal = signalName(ws.Signal())
+		exit.CoreDumped = ws.CoreDump()
+	}
+	return exit
+}
+
+// signalName returns the conventional name of a signal, e.g. "SIGTERM"
+func signalName(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGABRT:
+		return "SIGABRT"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGSEGV:
+		return "SIGSEGV"
+	case syscall.SIGPIPE:
+		return "SIGPIPE"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGBUS:
+		return "SIGBUS"
+	}
+	return sig.String()
+}
@@ -0,0 +1,188 @@
+package monitor
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenFile is an open file descriptor of a process
+type OpenFile struct {
+	FD       int    `json:"fd"`
+	Target   string `json:"target"`
+	Type     string `json:"type"`
+	Mode     string `json:"mode,omitempty"`
+	Position int64  `json:"position"`
+}
+
+// ProcessFiles lists the open file descriptors of a process
+type ProcessFiles struct {
+	PID       int        `json:"pid"`
+	Count     int        `json:"count"`
+	Limit     uint64     `json:"limit"`
+	HardLimit uint64     `json:"hardLimit"`
+	Files     []OpenFile `json:"files"`
+}
+
+// FileCollector reads /proc/{pid}/fd and fdinfo. ProcRoot can point at a
+// fixture directory laid out like /proc.
+type FileCollector struct {
+	ProcRoot string
+}
+
+// NewFileCollector creates a collector reading the live /proc
+func NewFileCollector() *FileCollector {
+	return &FileCollector{ProcRoot: "/proc"}
+}
+
+// Files returns all open file descriptors of pid
+func (c *FileCollector) Files(pid int) (*ProcessFiles, error) {
+	procDir := filepath.Join(c.ProcRoot, strconv.Itoa(pid))
+	entries, err := os.ReadDir(filepath.Join(procDir, "fd"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProcessFiles{
+		PID:   pid,
+		Files: []OpenFile{},
+	}
+	result.Limit, result.HardLimit = readFDLimit(c.ProcRoot, pid)
+
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		// The descriptor may be closed between listing and reading it
+		target, err := os.Readlink(filepath.Join(procDir, "fd", entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		file := OpenFile{
+			FD:     fd,
+			Target: target,
+			Type:   fdType(target),
+		}
+		file.Mode, file.Position = readFDInfo(filepath.Join(procDir, "fdinfo", entry.Name()))
+
+		result.Files = append(result.Files, file)
+	}
+
+	sort.Slice(result.Files, func(i, j int) bool {
+		return result.Files[i].FD < result.Files[j].FD
+	})
+	result.Count = len(result.Files)
+
+	return result, nil
+}
+
+// fdType classifies the target of an fd link
+func fdType(target string) string {
+	switch {
+	case strings.HasPrefix(target, "socket:"):
+		return "socket"
+	case strings.HasPrefix(target, "pipe:"):
+		return "pipe"
+	case strings.HasPrefix(target, "anon_inode:"):
+		return "anon_inode"
+	case strings.HasPrefix(target, "/dev/"):
+		return "device"
+	case strings.HasPrefix(target, "/"):
+		return "file"
+	default:
+		return "other"
+	}
+}
+
+// readFDInfo returns the access mode and file position from fdinfo
+func readFDInfo(path string) (string, int64) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0
+	}
+	defer f.Close()
+
+	var mode string
+	var pos int64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "pos":
+			pos, _ = strconv.ParseInt(value, 10, 64)
+		case "flags":
+			// Flags are printed in octal; the low two bits are O_ACCMODE
+			flags, err := strconv.ParseUint(value, 8, 64)
+			if err != nil {
+				continue
+			}
+			switch flags & 3 {
+			case 0:
+				mode = "r"
+			case 1:
+				mode = "w"
+			case 2:
+				mode = "rw"
+			}
+		}
+	}
+
+	return mode, pos
+}
+
+// countFDs returns the number of open file descriptors of pid
+func countFDs(procRoot string, pid int) int {
+	entries, err := os.ReadDir(filepath.Join(procRoot, strconv.Itoa(pid), "fd"))
+	if err != nil {
+		return 0
+	}
+	return len(entries)
+}
+
+// readFDLimit returns the soft and hard RLIMIT_NOFILE of pid from
+// /proc/{pid}/limits. An unlimited value is returned as 0.
+func readFDLimit(procRoot string, pid int) (uint64, uint64) {
+	f, err := os.Open(filepath.Join(procRoot, strconv.Itoa(pid), "limits"))
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) < 2 {
+			return 0, 0
+		}
+		soft, _ := strconv.ParseUint(fields[0], 10, 64)
+		hard, _ := strconv.ParseUint(fields[1], 10, 64)
+		return soft, hard
+	}
+
+	return 0, 0
+}
+
+// fdUsagePercent returns the open descriptors of pid as a percentage of
+// its soft RLIMIT_NOFILE
+func fdUsagePercent(procRoot string, pid int) float64 {
+	limit, _ := readFDLimit(procRoot, pid)
+	if limit == 0 {
+		return 0
+	}
+	return float64(countFDs(procRoot, pid)) / float64(limit) * 100
+}
@@ -0,0 +1,328 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitInfo is the repository state of a working directory, read from the
+// .git directory without running git
+type GitInfo struct {
+	// Root is the top of the working tree
+	Root string `json:"root"`
+	// Repo is the name of the repository, shared by all its worktrees
+	Repo string `json:"repo"`
+	// Worktree is the name of a linked worktree, empty for the main one
+	Worktree string `json:"worktree,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Detached bool   `json:"detached"`
+	Commit   string `json:"commit,omitempty"`
+	// Upstream is the tracked branch, e.g. "origin/main"
+	Upstream string `json:"upstream,omitempty"`
+
+	// Dirty counts tracked files that differ from the index, including
+	// deleted and conflicted files; staged changes are not compared
+	// against HEAD. Untracked counts files neither tracked nor ignored.
+	Dirty     int  `json:"dirty"`
+	Untracked int  `json:"untracked"`
+	Truncated bool `json:"truncated,omitempty"`
+	// StatusAt is when Dirty and Untracked were computed, 0 until the
+	// first background status scan has finished
+	StatusAt int64 `json:"statusAt"`
+
+	// commonDir is the git directory shared by all worktrees
+	commonDir string
+}
+
+// SessionName returns "repo@branch", or "repo@<commit>" on a detached HEAD
+func (g GitInfo) SessionName() string {
+	ref := g.Branch
+	if g.Detached {
+		ref = g.Commit
+		if len(ref) > 7 {
+			ref = ref[:7]
+		}
+	}
+	if ref == "" {
+		return g.Repo
+	}
+	return g.Repo + "@" + ref
+}
+
+const (
+	// gitStatusInterval is the maximum age of the dirty and untracked
+	// counts while the repository metadata does not change
+	gitStatusInterval = 30 * time.Second
+	// gitRepoTTL is how long a repository no session uses stays cached
+	gitRepoTTL = 10 * time.Minute
+)
+
+// GitMonitor caches the GitInfo of repositories. Branch and upstream are
+// reread when HEAD, the index, the config or the branch ref change; the
+// dirty and untracked counts are computed in the background.
+type GitMonitor struct {
+	mu    sync.Mutex
+	repos map[string]*gitRepo
+}
+
+type gitRepo struct {
+	gitDir        string
+	info          GitInfo
+	stamp         string
+	statusDue     bool
+	statusRunning bool
+	lastUsed      time.Time
+}
+
+// NewGitMonitor creates an empty cache
+func NewGitMonitor() *GitMonitor {
+	return &GitMonitor{repos: make(map[string]*gitRepo)}
+}
+
+// Lookup returns the repository state of dir, or nil if it is not inside
+// a git working tree
+func (m *GitMonitor) Lookup(dir string) *GitInfo {
+	root, gitDir, ok := findGitDir(dir)
+	if !ok {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for key, repo := range m.repos {
+		if now.Sub(repo.lastUsed) > gitRepoTTL && !repo.statusRunning {
+			delete(m.repos, key)
+		}
+	}
+
+	repo, ok := m.repos[root]
+	if !ok || repo.gitDir != gitDir {
+		repo = &gitRepo{gitDir: gitDir}
+		m.repos[root] = repo
+	}
+	repo.lastUsed = now
+
+	if stamp := gitStamp(gitDir, repo.info); stamp != repo.stamp || repo.info.Root == "" {
+		info, err := readGitInfo(root, gitDir)
+		if err != nil {
+			return nil
+		}
+		info.Dirty, info.Untracked = repo.info.Dirty, repo.info.Untracked
+		info.Truncated, info.StatusAt = repo.info.Truncated, repo.info.StatusAt
+		repo.info = info
+		// The stamp depends on the branch, so take it after reading HEAD
+		repo.stamp = gitStamp(gitDir, info)
+		repo.statusDue = true
+	}
+
+	if !repo.statusRunning && (repo.statusDue || now.Sub(time.Unix(repo.info.StatusAt, 0)) > gitStatusInterval) {
+		repo.statusRunning = true
+		repo.statusDue = false
+		go m.refreshStatus(root, gitDir)
+	}
+
+	info := repo.info
+	return &info
+}
+
+// refreshStatus computes the dirty and untracked counts of a repository
+func (m *GitMonitor) refreshStatus(root, gitDir string) {
+	status, err := readGitStatus(root, gitDir)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	repo, ok := m.repos[root]
+	if !ok {
+		return
+	}
+	repo.statusRunning = false
+	if err != nil {
+		return
+	}
+	repo.info.Dirty = status.dirty
+	repo.info.Untracked = status.untracked
+	repo.info.Truncated = status.truncated
+	repo.info.StatusAt = time.Now().Unix()
+}
+
+// findGitDir walks up from dir to the top of its working tree. The .git of
+// a linked worktree or submodule is a file pointing at the real directory.
+func findGitDir(dir string) (root, gitDir string, ok bool) {
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		dotGit := filepath.Join(d, ".git")
+		if fi, err := os.Stat(dotGit); err == nil {
+			if fi.IsDir() {
+				return d, dotGit, true
+			}
+			if target, found := strings.CutPrefix(readTrimmed(dotGit), "gitdir: "); found {
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(d, target)
+				}
+				return d, filepath.Clean(target), true
+			}
+		}
+		if d == filepath.Dir(d) {
+			return "", "", false
+		}
+	}
+}
+
+// gitCommonDir returns the directory holding refs and config, which linked
+// worktrees share with the main worktree
+func gitCommonDir(gitDir string) string {
+	common := readTrimmed(filepath.Join(gitDir, "commondir"))
+	if common == "" {
+		return gitDir
+	}
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return filepath.Clean(common)
+}
+
+// gitStamp fingerprints the files GitInfo is read from
+func gitStamp(gitDir string, info GitInfo) string {
+	common := gitCommonDir(gitDir)
+	files := []string{
+		filepath.Join(gitDir, "HEAD"),
+		filepath.Join(gitDir, "index"),
+		filepath.Join(common, "config"),
+		filepath.Join(common, "packed-refs"),
+	}
+	if info.Branch != "" {
+		files = append(files, filepath.Join(common, "refs", "heads", info.Branch))
+	}
+
+	var b strings.Builder
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			fmt.Fprintf(&b, "%d:%d;", fi.ModTime().UnixNano(), fi.Size())
+		} else {
+			b.WriteString("-;")
+		}
+	}
+	return b.String()
+}
+
+// gitRepoName returns the name of a repository from its common git dir
+func gitRepoName(common string) string {
+	if filepath.Base(common) == ".git" {
+		return filepath.Base(filepath.Dir(common))
+	}
+	return strings.TrimSuffix(filepath.Base(common), ".git") // bare
+}
+
+// readGitInfo reads HEAD, the branch ref and the branch's upstream
+func readGitInfo(root, gitDir string) (GitInfo, error) {
+	info := GitInfo{Root: root}
+
+	common := gitCommonDir(gitDir)
+	info.commonDir = common
+	if common != gitDir {
+		info.Worktree = filepath.Base(gitDir)
+	}
+	info.Repo = gitRepoName(common)
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return GitInfo{}, err
+	}
+	ref, symbolic := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: ")
+	if !symbolic {
+		info.Detached = true
+		info.Commit = ref
+		return info, nil
+	}
+
+	info.Branch = strings.TrimPrefix(ref, "refs/heads/")
+	info.Commit = resolveGitRef(common, ref)
+
+	config := readGitConfig(filepath.Join(common, "config"))
+	remote := config["branch."+info.Branch+".remote"]
+	merge := strings.TrimPrefix(config["branch."+info.Branch+".merge"], "refs/heads/")
+	switch {
+	case remote == "" || merge == "":
+	case remote == ".":
+		info.Upstream = merge // a local branch
+	default:
+		info.Upstream = remote + "/" + merge
+	}
+
+	return info, nil
+}
+
+// resolveGitRef returns the commit of a ref from its loose file or from
+// packed-refs, or "" for a branch without commits
+func resolveGitRef(commonDir, ref string) string {
+	if sha := readTrimmed(filepath.Join(commonDir, ref)); sha != "" {
+		return sha
+	}
+
+	f, err := os.Open(filepath.Join(commonDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sha, name, ok := strings.Cut(scanner.Text(), " ")
+		if ok && name == ref {
+			return sha
+		}
+	}
+	return ""
+}
+
+// readGitConfig parses the subset of the git config syntax used for
+// branch tracking. Keys are "section.subsection.key" with the section and
+// key lowercased.
+func readGitConfig(path string) map[string]string {
+	config := make(map[string]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return config
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			header := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			name, sub, hasSub := strings.Cut(header, " ")
+			section = strings.ToLower(name)
+			if hasSub {
+				section += "." + strings.Trim(strings.TrimSpace(sub), `"`)
+			}
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, "=")
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			if end := strings.Index(value[1:], `"`); end >= 0 {
+				value = value[1 : end+1]
+			}
+		} else if i := strings.IndexAny(value, "#;"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		config[section+"."+strings.ToLower(strings.TrimSpace(key))] = value
+	}
+
+	return config
+}
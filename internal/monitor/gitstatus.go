@@ -0,0 +1,407 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+const (
+	// gitStatusMaxEntries bounds the untracked file walk of huge trees
+	gitStatusMaxEntries = 100000
+	// gitHashMaxBytes is the largest file whose content is hashed when
+	// only its timestamp differs from the index
+	gitHashMaxBytes = 16 << 20
+)
+
+// Index entry modes that are not compared with the working tree
+const (
+	gitModeTypeMask = 0170000
+	gitModeSymlink  = 0120000
+	gitModeGitlink  = 0160000
+)
+
+type gitIndexEntry struct {
+	path      string
+	mode      uint32
+	mtimeSec  uint32
+	mtimeNsec uint32
+	size      uint32
+	hash      []byte
+	stage     int
+	// skip is set for skip-worktree and intent-to-add entries
+	skip bool
+}
+
+type gitStatus struct {
+	dirty     int
+	untracked int
+	truncated bool
+}
+
+// readGitStatus compares the index with the working tree, like
+// "git status --porcelain" without the HEAD to index comparison
+func readGitStatus(root, gitDir string) (gitStatus, error) {
+	var status gitStatus
+
+	config := readGitConfig(filepath.Join(gitCommonDir(gitDir), "config"))
+	newHash, hashSize := sha1.New, sha1.Size
+	if config["extensions.objectformat"] == "sha256" {
+		newHash, hashSize = sha256.New, sha256.Size
+	}
+
+	entries, err := readGitIndex(filepath.Join(gitDir, "index"), hashSize)
+	if err != nil && !os.IsNotExist(err) {
+		return status, err
+	}
+
+	tracked := make(map[string]bool, len(entries))
+	conflicted := make(map[string]bool)
+	for _, e := range entries {
+		tracked[e.path] = true
+		if e.stage > 0 {
+			conflicted[e.path] = true
+			continue
+		}
+		if !e.skip && gitEntryChanged(root, e, newHash) {
+			status.dirty++
+		}
+	}
+	status.dirty += len(conflicted)
+
+	status.untracked, status.truncated = countUntracked(root, gitDir, tracked)
+	return status, nil
+}
+
+// readGitIndex parses the entries of an index file, versions 2 to 4
+func readGitIndex(indexPath string, hashSize int) ([]gitIndexEntry, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, fmt.Errorf("%s: not a git index", indexPath)
+	}
+	version := binary.BigEndian.Uint32(data[4:])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("%s: unsupported index version %d", indexPath, version)
+	}
+	count := binary.BigEndian.Uint32(data[8:])
+
+	entries := make([]gitIndexEntry, 0, count)
+	prevPath := ""
+	off := 12
+	for i := uint32(0); i < count; i++ {
+		// ctime, mtime, dev, ino, mode, uid, gid, size, hash, flags
+		fixed := 40 + hashSize + 2
+		if off+fixed > len(data) {
+			return nil, fmt.Errorf("%s: truncated entry %d", indexPath, i)
+		}
+		start := off
+		e := gitIndexEntry{
+			mtimeSec:  binary.BigEndian.Uint32(data[off+8:]),
+			mtimeNsec: binary.BigEndian.Uint32(data[off+12:]),
+			mode:      binary.BigEndian.Uint32(data[off+24:]),
+			size:      binary.BigEndian.Uint32(data[off+36:]),
+			hash:      data[off+40 : off+40+hashSize],
+		}
+		flags := binary.BigEndian.Uint16(data[off+40+hashSize:])
+		e.stage = int(flags>>12) & 3
+		off += fixed
+
+		if flags&0x4000 != 0 && version >= 3 {
+			if off+2 > len(data) {
+				return nil, fmt.Errorf("%s: truncated entry %d", indexPath, i)
+			}
+			extended := binary.BigEndian.Uint16(data[off:])
+			e.skip = extended&0x4000 != 0 || extended&0x2000 != 0
+			off += 2
+		}
+
+		if version == 4 {
+			// The path replaces a number of trailing bytes of the previous one
+			strip, n := gitVarint(data[off:])
+			if n == 0 || strip > len(prevPath) {
+				return nil, fmt.Errorf("%s: invalid path of entry %d", indexPath, i)
+			}
+			off += n
+			end := bytes.IndexByte(data[off:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("%s: truncated entry %d", indexPath, i)
+			}
+			e.path = prevPath[:len(prevPath)-strip] + string(data[off:off+end])
+			off += end + 1
+		} else {
+			end := bytes.IndexByte(data[off:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("%s: truncated entry %d", indexPath, i)
+			}
+			e.path = string(data[off : off+end])
+			// Entries are NUL-padded to a multiple of 8 bytes
+			off = start + (off-start+end+8)&^7
+		}
+
+		prevPath = e.path
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// gitVarint decodes the offset encoding used by index version 4
+func gitVarint(b []byte) (int, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	c := b[0]
+	val := int(c & 0x7f)
+	n := 1
+	for c&0x80 != 0 {
+		if n >= len(b) {
+			return 0, 0
+		}
+		c = b[n]
+		n++
+		val = ((val + 1) << 7) | int(c&0x7f)
+	}
+	return val, n
+}
+
+// gitEntryChanged reports whether the working tree file of an index entry
+// was modified or deleted. Files whose size matches but whose timestamp
+// does not are hashed to rule out a mere touch.
+func gitEntryChanged(root string, e gitIndexEntry, newHash func() hash.Hash) bool {
+	if e.mode&gitModeTypeMask == gitModeGitlink {
+		return false // Submodules have their own status
+	}
+
+	full := filepath.Join(root, filepath.FromSlash(e.path))
+	var st syscall.Stat_t
+	if err := syscall.Lstat(full, &st); err != nil {
+		return true
+	}
+
+	isLink := st.Mode&syscall.S_IFMT == syscall.S_IFLNK
+	if isLink != (e.mode&gitModeTypeMask == gitModeSymlink) {
+		return true
+	}
+	if !isLink && (st.Mode&0100 != 0) != (e.mode&0100 != 0) {
+		return true
+	}
+	if uint32(st.Size) != e.size {
+		return true
+	}
+	if uint32(st.Mtim.Sec) == e.mtimeSec && (e.mtimeNsec == 0 || uint32(st.Mtim.Nsec) == e.mtimeNsec) {
+		return false
+	}
+
+	if st.Size > gitHashMaxBytes {
+		return true
+	}
+	var content []byte
+	var err error
+	if isLink {
+		var target string
+		target, err = os.Readlink(full)
+		content = []byte(target)
+	} else {
+		content, err = os.ReadFile(full)
+	}
+	if err != nil {
+		return true
+	}
+
+	h := newHash()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return !bytes.Equal(h.Sum(nil), e.hash)
+}
+
+// gitIgnoreRule is one line of a .gitignore file
+type gitIgnoreRule struct {
+	// base is the directory of the .gitignore relative to the root
+	base     string
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func (r gitIgnoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		sub, ok := strings.CutPrefix(rel, r.base+"/")
+		if !ok {
+			return false
+		}
+		rel = sub
+	}
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	return r.re.MatchString(rel)
+}
+
+// readGitIgnore parses a .gitignore or info/exclude file
+func readGitIgnore(file, base string) []gitIgnoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []gitIgnoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		rule := gitIgnoreRule{base: base}
+		if line[0] == '!' {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(globToRegexp(line))
+		if err != nil {
+			continue
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// globToRegexp translates a gitignore pattern, including "**", to an
+// anchored regular expression
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end <= 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if class[0] == '!' {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// countUntracked walks the working tree for files that are neither
+// tracked nor ignored. Nested repositories count as one entry.
+func countUntracked(root, gitDir string, tracked map[string]bool) (int, bool) {
+	rules := map[string][]gitIgnoreRule{
+		"": append(readGitIgnore(filepath.Join(gitCommonDir(gitDir), "info", "exclude"), ""),
+			readGitIgnore(filepath.Join(root, ".gitignore"), "")...),
+	}
+	ignored := func(rel string, isDir bool) bool {
+		result := false
+		check := func(dir string) {
+			for _, r := range rules[dir] {
+				if r.match(rel, isDir) {
+					result = !r.negate
+				}
+			}
+		}
+		check("")
+		for i := 0; i < len(rel); i++ {
+			if rel[i] == '/' {
+				check(rel[:i])
+			}
+		}
+		return result
+	}
+
+	untracked, visited := 0, 0
+	truncated := false
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root {
+			return nil
+		}
+		if visited++; visited > gitStatusMaxEntries {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		rel := filepath.ToSlash(p[len(root)+1:])
+		if d.Name() == ".git" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if tracked[rel] {
+				return filepath.SkipDir // Submodule
+			}
+			if ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Lstat(filepath.Join(p, ".git")); err == nil {
+				untracked++
+				return filepath.SkipDir
+			}
+			rules[rel] = readGitIgnore(filepath.Join(p, ".gitignore"), rel)
+			return nil
+		}
+
+		if !tracked[rel] && !ignored(rel, false) {
+			untracked++
+		}
+		return nil
+	})
+
+	return untracked, truncated
+}
@@ -1,6 +1,9 @@
 package monitor
 
 import (
+	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,86 +19,661 @@ const (
 
 // HistoryPoint represents a single point in time
 type HistoryPoint struct {
-	Timestamp   int64              `json:"timestamp"`
-	Temperature float64            `json:"temperature"`
-	Processes   []ProcessSnapshot  `json:"processes"`
+	Timestamp int64 `json:"timestamp"`
+	// Temperature is the main temperature, nil without temperature sensors
+	Temperature *float64          `json:"temperature"`
+	Processes   []ProcessSnapshot `json:"processes"`
+	Sensors     []SensorSample    `json:"sensors,omitempty"`
+
+	PackageWatts float64 `json:"packageWatts"`
+	AvgFreqMHz   float64 `json:"avgFreqMhz"`
+	Throttling   bool    `json:"throttling"`
+	// Core and package throttle events since the previous point
+	CoreThrottleEvents    uint64 `json:"coreThrottleEvents"`
+	PackageThrottleEvents uint64 `json:"packageThrottleEvents"`
+
+	System SystemSample `json:"system"`
+	Power  PowerSample  `json:"power"`
+}
+
+// PowerSample is the battery state at a history point
+type PowerSample struct {
+	OnBattery        bool    `json:"onBattery"`
+	CapacityPercent  float64 `json:"capacityPercent"`
+	DrainWatts       float64 `json:"drainWatts"`
+	ClaudeDrainWatts float64 `json:"claudeDrainWatts"`
+}
+
+// SystemSample is the machine-wide load at a history point. Pressure
+// values are the "some" avg10 percentages.
+type SystemSample struct {
+	CPUPercent       float64 `json:"cpuPercent"`
+	ClaudeCPUPercent float64 `json:"claudeCpuPercent"`
+	Load1            float64 `json:"load1"`
+	MemUsedPercent   float64 `json:"memUsedPercent"`
+	SwapUsedPercent  float64 `json:"swapUsedPercent"`
+	CPUPressure      float64 `json:"cpuPressure"`
+	MemoryPressure   float64 `json:"memoryPressure"`
+	IOPressure       float64 `json:"ioPressure"`
+}
+
+// NewSystemSample condenses a SystemStatus for the history
+func NewSystemSample(s SystemStatus) SystemSample {
+	return SystemSample{
+		CPUPercent:       s.CPUPercent,
+		ClaudeCPUPercent: s.ClaudeCPUPercent,
+		Load1:            s.Load1,
+		MemUsedPercent:   s.Memory.UsedPercent,
+		SwapUsedPercent:  s.Memory.SwapUsedPercent,
+		CPUPressure:      s.Pressure["cpu"].Some.Avg10,
+		MemoryPressure:   s.Pressure["memory"].Some.Avg10,
+		IOPressure:       s.Pressure["io"].Some.Avg10,
+	}
+}
+
+// SensorSample is the value of one sensor at a history point
+type SensorSample struct {
+	ID    string  `json:"id"`
+	Type  string  `json:"type"`
+	Value float64 `json:"value"`
 }
 
 // ProcessSnapshot is a snapshot of process metrics
 type ProcessSnapshot struct {
-	PID        int     `json:"pid"`
-	Name       string  `json:"name"`
-	CPUPercent float64 `json:"cpuPercent"`
-	MemoryMB   float64 `json:"memoryMb"`
+	// ID is the process identity that series are keyed by
+	ID              string  `json:"id"`
+	PID             int     `json:"pid"`
+	Name            string  `json:"name"`
+	CPUPercent      float64 `json:"cpuPercent"`
+	CPUPercentTotal float64 `json:"cpuPercentTotal"`
+	MemoryMB        float64 `json:"memoryMb"`
+	PSSMB           float64 `json:"pssMb"`
+	USSMB           float64 `json:"ussMb"`
+	SwapMB          float64 `json:"swapMb"`
+	Threads         int     `json:"threads"`
+
+	ReadBytesPerSec  float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
+	Watts            float64 `json:"watts"`
 }
 
-// HistoryBuffer is a ring buffer for history
+// ProcessSeries is the history of one process as parallel arrays: the
+// values at index i were sampled at Timestamps[i]
+type ProcessSeries struct {
+	ID   string `json:"id"`
+	PID  int    `json:"pid"`
+	Name string `json:"name"`
+
+	Timestamps       []int64   `json:"timestamps"`
+	CPUPercent       []float64 `json:"cpuPercent"`
+	CPUPercentTotal  []float64 `json:"cpuPercentTotal"`
+	MemoryMB         []float64 `json:"memoryMb"`
+	PSSMB            []float64 `json:"pssMb"`
+	ReadBytesPerSec  []float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec []float64 `json:"writeBytesPerSec"`
+	Watts            []float64 `json:"watts"`
+	// Temperature is the main temperature of the machine at each sample,
+	// nil where it was not available
+	Temperature []*float64 `json:"temperature"`
+
+	// Summary holds the statistics of each series by its JSON name
+	Summary map[string]SeriesSummary `json:"summary"`
+}
+
+// SeriesSummary describes the values of a series
+type SeriesSummary struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+	P95 float64 `json:"p95"`
+}
+
+// Metrics kept per process sample, in the order of processSeries.metrics
+const (
+	metricCPU = iota
+	metricCPUTotal
+	metricMemory
+	metricPSS
+	metricUSS
+	metricSwap
+	metricRead
+	metricWrite
+	metricWatts
+	metricThreads
+	numProcessMetrics
+)
+
+// HistoryBuffer keeps the last points of history in columns: one ring per
+// machine-wide value and one series per process identity and sensor, so
+// memory grows with the number of series rather than with points times
+// processes. Timestamps are stored as deltas to the previous point and
+// identities and names once per series. Points are rebuilt on read.
 type HistoryBuffer struct {
-	mu      sync.RWMutex
-	data    []HistoryPoint
-	head    int
-	count   int
+	mu       sync.RWMutex
+	capacity int
+	// next is the sequence number of the next point; the points held are
+	// next-count to next-1, at index seq % capacity of the rings
+	next  uint64
+	count int
+
+	firstTime    int64 // Timestamp of the oldest point
+	lastTime     int64
+	timeDeltas   []int32   // Seconds since the previous point
+	temperature  []float64 // NaN where there was none
+	packageWatts []float64
+	avgFreqMHz   []float64
+	throttling   []bool
+	coreThrottle []uint64
+	pkgThrottle  []uint64
+	system       []SystemSample
+	power        []PowerSample
+
+	interned  internTable
+	processes map[string]*processSeries
+	// procOrder and sensorOrder keep the series in order of appearance
+	procOrder   []*processSeries
+	sensors     map[sensorKey]*sensorSeries
+	sensorOrder []*sensorSeries
+}
+
+// seriesIndex maps the samples of a series to the points they belong to.
+// Samples of consecutive points form one run.
+type seriesIndex struct {
+	runs   []seriesRun
+	length int
 }
 
-// NewHistoryBuffer creates a new history buffer
+type seriesRun struct {
+	seq uint64
+	n   int
+}
+
+func (s *seriesIndex) push(seq uint64) bool {
+	if n := len(s.runs); n > 0 {
+		last := &s.runs[n-1]
+		if end := last.seq + uint64(last.n); end > seq {
+			return false // Already sampled at this point
+		} else if end == seq {
+			last.n++
+			s.length++
+			return true
+		}
+	}
+	s.runs = append(s.runs, seriesRun{seq: seq, n: 1})
+	s.length++
+	return true
+}
+
+// trim drops the samples of points before seq and returns their number
+func (s *seriesIndex) trim(seq uint64) int {
+	dropped := 0
+	for len(s.runs) > 0 {
+		r := &s.runs[0]
+		if r.seq+uint64(r.n) <= seq {
+			dropped += r.n
+			s.runs = s.runs[1:]
+			continue
+		}
+		if r.seq < seq {
+			k := int(seq - r.seq)
+			dropped += k
+			r.seq, r.n = seq, r.n-k
+		}
+		break
+	}
+	s.length -= dropped
+	return dropped
+}
+
+// processSeries holds the samples of one process identity
+type processSeries struct {
+	seriesIndex
+	id      string
+	pid     int
+	metrics [numProcessMetrics][]float32
+	// names are the names from sample index from on, as processes can be
+	// renamed by an alias
+	names []nameRun
+}
+
+type nameRun struct {
+	from int
+	name string
+}
+
+type sensorKey struct {
+	id  string
+	typ string
+}
+
+// sensorSeries holds the values of one sensor
+type sensorSeries struct {
+	seriesIndex
+	key    sensorKey
+	values []float64
+}
+
+// internTable shares one copy of each identity and name among all series
+// and releases it with the last series using it
+type internTable map[string]*internedString
+
+type internedString struct {
+	s    string
+	refs int
+}
+
+func (t internTable) intern(s string) string {
+	e, ok := t[s]
+	if !ok {
+		e = &internedString{s: strings.Clone(s)}
+		t[s] = e
+	}
+	e.refs++
+	return e.s
+}
+
+func (t internTable) release(s string) {
+	if e, ok := t[s]; ok {
+		if e.refs--; e.refs <= 0 {
+			delete(t, s)
+		}
+	}
+}
+
+// NewHistoryBuffer creates a buffer for HistoryDuration of history
 func NewHistoryBuffer() *HistoryBuffer {
+	return NewHistoryBufferSize(MaxSamples)
+}
+
+// NewHistoryBufferSize creates a buffer keeping the given number of points
+func NewHistoryBufferSize(samples int) *HistoryBuffer {
+	samples = max(samples, 1)
 	return &HistoryBuffer{
-		data: make([]HistoryPoint, MaxSamples),
+		capacity:     samples,
+		timeDeltas:   make([]int32, samples),
+		temperature:  make([]float64, samples),
+		packageWatts: make([]float64, samples),
+		avgFreqMHz:   make([]float64, samples),
+		throttling:   make([]bool, samples),
+		coreThrottle: make([]uint64, samples),
+		pkgThrottle:  make([]uint64, samples),
+		system:       make([]SystemSample, samples),
+		power:        make([]PowerSample, samples),
+		interned:     make(internTable),
+		processes:    make(map[string]*processSeries),
+		sensors:      make(map[sensorKey]*sensorSeries),
 	}
 }
 
-// Add adds a new history point
+// Add adds a new history point, dropping the oldest one when full
 func (hb *HistoryBuffer) Add(point HistoryPoint) {
 	hb.mu.Lock()
 	defer hb.mu.Unlock()
 
-	hb.data[hb.head] = point
-	hb.head = (hb.head + 1) % MaxSamples
-	if hb.count < MaxSamples {
-		hb.count++
+	seq := hb.next
+	i := int(seq % uint64(hb.capacity))
+	full := hb.count == hb.capacity
+	if full {
+		hb.count--
+		if hb.count > 0 {
+			hb.firstTime += int64(hb.timeDeltas[(seq-uint64(hb.count))%uint64(hb.capacity)])
+		}
 	}
+	if hb.count == 0 {
+		hb.firstTime = point.Timestamp
+		hb.timeDeltas[i] = 0
+	} else {
+		hb.timeDeltas[i] = int32(point.Timestamp - hb.lastTime)
+	}
+	hb.lastTime = point.Timestamp
+	hb.temperature[i] = math.NaN()
+	if point.Temperature != nil {
+		hb.temperature[i] = *point.Temperature
+	}
+	hb.packageWatts[i] = point.PackageWatts
+	hb.avgFreqMHz[i] = point.AvgFreqMHz
+	hb.throttling[i] = point.Throttling
+	hb.coreThrottle[i] = point.CoreThrottleEvents
+	hb.pkgThrottle[i] = point.PackageThrottleEvents
+	hb.system[i] = point.System
+	hb.power[i] = point.Power
+	hb.count++
+	hb.next++
+
+	for _, p := range point.Processes {
+		s, ok := hb.processes[p.ID]
+		if !ok {
+			s = &processSeries{id: hb.interned.intern(p.ID), pid: p.PID}
+			hb.processes[s.id] = s
+			hb.procOrder = append(hb.procOrder, s)
+		}
+		index := s.length
+		if !s.push(seq) {
+			continue
+		}
+		if n := len(s.names); n == 0 || s.names[n-1].name != p.Name {
+			s.names = append(s.names, nameRun{from: index, name: hb.interned.intern(p.Name)})
+		}
+		m := &s.metrics
+		m[metricCPU] = append(m[metricCPU], float32(p.CPUPercent))
+		m[metricCPUTotal] = append(m[metricCPUTotal], float32(p.CPUPercentTotal))
+		m[metricMemory] = append(m[metricMemory], float32(p.MemoryMB))
+		m[metricPSS] = append(m[metricPSS], float32(p.PSSMB))
+		m[metricUSS] = append(m[metricUSS], float32(p.USSMB))
+		m[metricSwap] = append(m[metricSwap], float32(p.SwapMB))
+		m[metricRead] = append(m[metricRead], float32(p.ReadBytesPerSec))
+		m[metricWrite] = append(m[metricWrite], float32(p.WriteBytesPerSec))
+		m[metricWatts] = append(m[metricWatts], float32(p.Watts))
+		m[metricThreads] = append(m[metricThreads], float32(p.Threads))
+	}
+
+	for _, sample := range point.Sensors {
+		key := sensorKey{id: sample.ID, typ: sample.Type}
+		s, ok := hb.sensors[key]
+		if !ok {
+			key = sensorKey{id: hb.interned.intern(sample.ID), typ: hb.interned.intern(sample.Type)}
+			s = &sensorSeries{key: key}
+			hb.sensors[key] = s
+			hb.sensorOrder = append(hb.sensorOrder, s)
+		}
+		if s.push(seq) {
+			s.values = append(s.values, sample.Value)
+		}
+	}
+
+	if full {
+		hb.trim(hb.next - uint64(hb.count))
+	}
+}
+
+// trim drops the samples of points before seq and series left empty
+func (hb *HistoryBuffer) trim(seq uint64) {
+	procs := hb.procOrder[:0]
+	for _, s := range hb.procOrder {
+		if k := s.trim(seq); k > 0 {
+			for m := range s.metrics {
+				s.metrics[m] = s.metrics[m][k:]
+			}
+			for j := range s.names {
+				s.names[j].from -= k
+			}
+			for len(s.names) > 1 && s.names[1].from <= 0 {
+				hb.interned.release(s.names[0].name)
+				s.names = s.names[1:]
+			}
+			s.names[0].from = 0
+		}
+		if s.length > 0 {
+			procs = append(procs, s)
+			continue
+		}
+		for _, n := range s.names {
+			hb.interned.release(n.name)
+		}
+		hb.interned.release(s.id)
+		delete(hb.processes, s.id)
+	}
+	clear(hb.procOrder[len(procs):])
+	hb.procOrder = procs
+
+	sensors := hb.sensorOrder[:0]
+	for _, s := range hb.sensorOrder {
+		if k := s.trim(seq); k > 0 {
+			s.values = s.values[k:]
+		}
+		if s.length > 0 {
+			sensors = append(sensors, s)
+			continue
+		}
+		hb.interned.release(s.key.id)
+		hb.interned.release(s.key.typ)
+		delete(hb.sensors, s.key)
+	}
+	clear(hb.sensorOrder[len(sensors):])
+	hb.sensorOrder = sensors
 }
 
 // GetAll returns all history points in chronological order
 func (hb *HistoryBuffer) GetAll() []HistoryPoint {
+	return hb.GetLast(math.MaxInt)
+}
+
+// GetLast returns the last N history points
+func (hb *HistoryBuffer) GetLast(n int) []HistoryPoint {
 	hb.mu.RLock()
 	defer hb.mu.RUnlock()
 
-	if hb.count == 0 {
+	n = min(n, hb.count)
+	if n <= 0 {
 		return nil
 	}
+	first := hb.next - uint64(n)
 
-	result := make([]HistoryPoint, hb.count)
+	result := make([]HistoryPoint, n)
+	ts := hb.firstTime
+	for seq := hb.next - uint64(hb.count); seq < hb.next; seq++ {
+		i := seq % uint64(hb.capacity)
+		if seq > hb.next-uint64(hb.count) {
+			ts += int64(hb.timeDeltas[i])
+		}
+		if seq < first {
+			continue
+		}
+		result[seq-first] = HistoryPoint{
+			Timestamp:    ts,
+			Temperature:  optionalValue(hb.temperature[i]),
+			PackageWatts: hb.packageWatts[i],
+			AvgFreqMHz:   hb.avgFreqMHz[i],
+			Throttling:   hb.throttling[i],
 
-	if hb.count < MaxSamples {
-		// Buffer not full yet, data starts at 0
-		copy(result, hb.data[:hb.count])
-	} else {
-		// Buffer is full, oldest data is at head
-		firstPart := hb.data[hb.head:]
-		secondPart := hb.data[:hb.head]
-		copy(result, firstPart)
-		copy(result[len(firstPart):], secondPart)
+			CoreThrottleEvents:    hb.coreThrottle[i],
+			PackageThrottleEvents: hb.pkgThrottle[i],
+
+			System: hb.system[i],
+			Power:  hb.power[i],
+		}
+	}
+
+	// Snapshots of all points share one array, cut per point by counting
+	// the samples of each point first
+	procCounts := make([]int, n)
+	sensorCounts := make([]int, n)
+	for _, s := range hb.procOrder {
+		s.countFrom(first, procCounts)
+	}
+	for _, s := range hb.sensorOrder {
+		s.countFrom(first, sensorCounts)
+	}
+	procs := make([]ProcessSnapshot, sum(procCounts))
+	sensors := make([]SensorSample, sum(sensorCounts))
+	for j := range result {
+		if c := procCounts[j]; c > 0 {
+			result[j].Processes, procs = procs[:0:c], procs[c:]
+		}
+		if c := sensorCounts[j]; c > 0 {
+			result[j].Sensors, sensors = sensors[:0:c], sensors[c:]
+		}
+	}
+
+	for _, s := range hb.procOrder {
+		name := 0
+		s.each(first, func(seq uint64, k int) {
+			for name+1 < len(s.names) && s.names[name+1].from <= k {
+				name++
+			}
+			m := &s.metrics
+			point := &result[seq-first]
+			point.Processes = append(point.Processes, ProcessSnapshot{
+				ID:               s.id,
+				PID:              s.pid,
+				Name:             s.names[name].name,
+				CPUPercent:       float64(m[metricCPU][k]),
+				CPUPercentTotal:  float64(m[metricCPUTotal][k]),
+				MemoryMB:         float64(m[metricMemory][k]),
+				PSSMB:            float64(m[metricPSS][k]),
+				USSMB:            float64(m[metricUSS][k]),
+				SwapMB:           float64(m[metricSwap][k]),
+				Threads:          int(m[metricThreads][k]),
+				ReadBytesPerSec:  float64(m[metricRead][k]),
+				WriteBytesPerSec: float64(m[metricWrite][k]),
+				Watts:            float64(m[metricWatts][k]),
+			})
+		})
+	}
+	for _, s := range hb.sensorOrder {
+		s.each(first, func(seq uint64, k int) {
+			point := &result[seq-first]
+			point.Sensors = append(point.Sensors, SensorSample{ID: s.key.id, Type: s.key.typ, Value: s.values[k]})
+		})
 	}
 
 	return result
 }
 
-// GetLast returns the last N history points
-func (hb *HistoryBuffer) GetLast(n int) []HistoryPoint {
-	all := hb.GetAll()
-	if len(all) <= n {
-		return all
+// each calls fn with the point and index of each sample from point first on
+func (s *seriesIndex) each(first uint64, fn func(seq uint64, k int)) {
+	k := 0
+	for _, r := range s.runs {
+		for j := 0; j < r.n; j++ {
+			if seq := r.seq + uint64(j); seq >= first {
+				fn(seq, k)
+			}
+			k++
+		}
+	}
+}
+
+// countFrom adds the samples of each point from first on to counts
+func (s *seriesIndex) countFrom(first uint64, counts []int) {
+	for _, r := range s.runs {
+		for j := 0; j < r.n; j++ {
+			if seq := r.seq + uint64(j); seq >= first {
+				counts[seq-first]++
+			}
+		}
+	}
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// ProcessSeries returns the samples of the process with the given identity
+// taken at or after since, or false if there are none
+func (hb *HistoryBuffer) ProcessSeries(id string, since int64) (ProcessSeries, bool) {
+	hb.mu.RLock()
+	defer hb.mu.RUnlock()
+
+	s, ok := hb.processes[id]
+	if !ok {
+		return ProcessSeries{}, false
+	}
+	n := s.length
+	series := ProcessSeries{
+		ID:               s.id,
+		PID:              s.pid,
+		Name:             s.names[len(s.names)-1].name,
+		Timestamps:       make([]int64, 0, n),
+		CPUPercent:       make([]float64, 0, n),
+		CPUPercentTotal:  make([]float64, 0, n),
+		MemoryMB:         make([]float64, 0, n),
+		PSSMB:            make([]float64, 0, n),
+		ReadBytesPerSec:  make([]float64, 0, n),
+		WriteBytesPerSec: make([]float64, 0, n),
+		Watts:            make([]float64, 0, n),
+		Temperature:      make([]*float64, 0, n),
+	}
+
+	// Timestamps are found by adding up deltas up to each sample
+	oldest := hb.next - uint64(hb.count)
+	at, ts := oldest, hb.firstTime
+	s.each(oldest, func(seq uint64, k int) {
+		for at < seq {
+			at++
+			ts += int64(hb.timeDeltas[at%uint64(hb.capacity)])
+		}
+		if ts < since {
+			return
+		}
+		m := &s.metrics
+		series.Timestamps = append(series.Timestamps, ts)
+		series.CPUPercent = append(series.CPUPercent, float64(m[metricCPU][k]))
+		series.CPUPercentTotal = append(series.CPUPercentTotal, float64(m[metricCPUTotal][k]))
+		series.MemoryMB = append(series.MemoryMB, float64(m[metricMemory][k]))
+		series.PSSMB = append(series.PSSMB, float64(m[metricPSS][k]))
+		series.ReadBytesPerSec = append(series.ReadBytesPerSec, float64(m[metricRead][k]))
+		series.WriteBytesPerSec = append(series.WriteBytesPerSec, float64(m[metricWrite][k]))
+		series.Watts = append(series.Watts, float64(m[metricWatts][k]))
+		series.Temperature = append(series.Temperature, optionalValue(hb.temperature[seq%uint64(hb.capacity)]))
+	})
+	if len(series.Timestamps) == 0 {
+		return ProcessSeries{}, false
+	}
+
+	series.Summary = map[string]SeriesSummary{
+		"cpuPercent":       Summarize(series.CPUPercent),
+		"cpuPercentTotal":  Summarize(series.CPUPercentTotal),
+		"memoryMb":         Summarize(series.MemoryMB),
+		"pssMb":            Summarize(series.PSSMB),
+		"readBytesPerSec":  Summarize(series.ReadBytesPerSec),
+		"writeBytesPerSec": Summarize(series.WriteBytesPerSec),
+		"watts":            Summarize(series.Watts),
+		"temperature":      SummarizeOptional(series.Temperature),
+	}
+	return series, true
+}
+
+// SummarizeOptional summarizes the values that are present
+func SummarizeOptional(values []*float64) SeriesSummary {
+	present := make([]float64, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			present = append(present, *v)
+		}
+	}
+	return Summarize(present)
+}
+
+// optionalValue returns nil for NaN, else a pointer to v
+func optionalValue(v float64) *float64 {
+	if math.IsNaN(v) {
+		return nil
+	}
+	return &v
+}
+
+// Summarize returns the minimum, mean, maximum and 95th percentile
+// (nearest rank) of values
+func Summarize(values []float64) SeriesSummary {
+	if len(values) == 0 {
+		return SeriesSummary{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return SeriesSummary{
+		Min: sorted[0],
+		Avg: sum / float64(len(sorted)),
+		Max: sorted[len(sorted)-1],
+		P95: sorted[rank],
 	}
-	return all[len(all)-n:]
 }
 
 // Clear clears all history
 func (hb *HistoryBuffer) Clear() {
 	hb.mu.Lock()
 	defer hb.mu.Unlock()
+	hb.trim(hb.next)
 	hb.count = 0
-	hb.head = 0
 }
 
 // Count returns the number of history points
@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HwmonCollector reads sensors from /sys/class/hwmon without forking
+// lm-sensors. SysfsRoot can point at a fixture directory laid out like /sys.
+type HwmonCollector struct {
+	SysfsRoot string
+}
+
+// NewHwmonCollector creates a collector reading the live /sys
+func NewHwmonCollector() *HwmonCollector {
+	return &HwmonCollector{SysfsRoot: "/sys"}
+}
+
+var hwmonInputPattern = regexp.MustCompile(`^(temp|fan|in|curr|power)(\d+)_(input|average)$`)
+
+// hwmonKindOrder is the order in which sensor types of a chip are listed
+var hwmonKindOrder = []string{"temp", "fan", "in", "curr", "power"}
+
+// hwmonChip is a hwmon device directory with a unique chip name
+type hwmonChip struct {
+	name string
+	dir  string
+}
+
+// chips returns all hwmon devices. Chips sharing a driver name (e.g. two
+// NVMe drives) are told apart by the name of their parent device.
+func (c *HwmonCollector) chips() []hwmonChip {
+	dirs, err := filepath.Glob(filepath.Join(c.SysfsRoot, "class", "hwmon", "hwmon*"))
+	if err != nil {
+		return nil
+	}
+
+	var chips []hwmonChip
+	nameCount := make(map[string]int)
+	for _, dir := range dirs {
+		name := readTrimmed(filepath.Join(dir, "name"))
+		if name == "" {
+			name = filepath.Base(dir)
+		}
+		nameCount[name]++
+		chips = append(chips, hwmonChip{name: name, dir: dir})
+	}
+
+	for i, chip := range chips {
+		if nameCount[chip.name] < 2 {
+			continue
+		}
+		suffix := filepath.Base(chip.dir)
+		if target, err := os.Readlink(filepath.Join(chip.dir, "device")); err == nil {
+			suffix = filepath.Base(target)
+		}
+		chips[i].name = chip.name + "-" + suffix
+	}
+
+	sort.Slice(chips, func(i, j int) bool {
+		return chips[i].name < chips[j].name
+	})
+
+	return chips
+}
+
+// Temperatures returns all temp*_input readings with their labels and
+// hardware limits
+func (c *HwmonCollector) Temperatures() []Temperature {
+	return temperaturesFromSensors(c.Sensors())
+}
+
+// Sensors returns all temperature, fan, voltage, current and power
+// readings grouped by chip
+func (c *HwmonCollector) Sensors() []Sensor {
+	var sensors []Sensor
+
+	for _, chip := range c.chips() {
+		// Older drivers expose the attributes below device/
+		for _, dir := range []string{chip.dir, filepath.Join(chip.dir, "device")} {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+
+			// Collect channel numbers per prefix, e.g. fan1, fan2
+			channels := make(map[string][]int)
+			seen := make(map[string]bool)
+			for _, entry := range entries {
+				m := hwmonInputPattern.FindStringSubmatch(entry.Name())
+				if m == nil || seen[m[1]+m[2]] {
+					continue
+				}
+				seen[m[1]+m[2]] = true
+				n, _ := strconv.Atoi(m[2])
+				channels[m[1]] = append(channels[m[1]], n)
+			}
+
+			for _, prefix := range hwmonKindOrder {
+				sort.Ints(channels[prefix])
+				for _, n := range channels[prefix] {
+					if s, ok := readHwmonSensor(dir, chip.name, prefix, n); ok {
+						sensors = append(sensors, s)
+					}
+				}
+			}
+		}
+	}
+
+	return sensors
+}
+
+// readHwmonSensor reads the attributes of one channel, e.g. fan2_*
+func readHwmonSensor(dir, chip, prefix string, n int) (Sensor, bool) {
+	kind := sensorKinds[prefix]
+	base := filepath.Join(dir, prefix+strconv.Itoa(n)+"_")
+
+	read := func(attr string) *float64 {
+		data, err := os.ReadFile(base + attr)
+		if err != nil {
+			return nil
+		}
+		val, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+		if err != nil {
+			return nil
+		}
+		val /= kind.scale
+		return &val
+	}
+
+	// Power meters report an average and/or an instantaneous value
+	value, err := os.ReadFile(base + "input")
+	if prefix == "power" {
+		if avg, avgErr := os.ReadFile(base + "average"); avgErr == nil {
+			value, err = avg, nil
+		}
+	}
+	if err != nil {
+		return Sensor{}, false
+	}
+	raw, err := strconv.ParseFloat(strings.TrimSpace(string(value)), 64)
+	if err != nil {
+		return Sensor{}, false
+	}
+
+	label := readTrimmed(base + "label")
+	if label == "" {
+		label = prefix + strconv.Itoa(n)
+	}
+
+	s := Sensor{
+		Chip:  chip,
+		Label: label,
+		Type:  kind.typ,
+		Value: raw / kind.scale,
+		Unit:  kind.unit,
+		Min:   read("min"),
+		Max:   read("max"),
+		Crit:  read("crit"),
+		Alarm: readTrimmed(base+"alarm") == "1" || readTrimmed(base+"crit_alarm") == "1",
+	}
+	if s.Max == nil && prefix == "power" {
+		s.Max = read("cap")
+	}
+
+	return s, true
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,143 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readBootID returns the random ID the kernel generates at every boot,
+// shortened to 8 hex digits, or "" if it is unavailable
+func readBootID(procRoot string) string {
+	id := strings.ReplaceAll(readTrimmed(filepath.Join(procRoot, "sys", "kernel", "random", "boot_id")), "-", "")
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return id
+}
+
+// processID builds the identity of a process. PIDs are reused, but the
+// combination with the boot and the start time in clock ticks is not.
+func processID(pid int, bootID string, startTicks uint64) string {
+	return fmt.Sprintf("%d-%s-%d", pid, bootID, startTicks)
+}
+
+// sameProcess reports whether st is the stat of the process with the given
+// identity rather than of a later process that reused its PID
+func sameProcess(id string, st procStat) bool {
+	return strings.HasSuffix(id, "-"+strconv.FormatUint(st.starttime, 10))
+}
+
+// ParseProcessKey accepts a PID or a process identity and returns the PID
+func ParseProcessKey(key string) (int, error) {
+	pidStr, _, _ := strings.Cut(key, "-")
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid process %q", key)
+	}
+	return pid, nil
+}
+
+// ErrProcessGone is returned for a process identity whose process has
+// exited, even if its PID has been reused
+var ErrProcessGone = errors.New("process has exited")
+
+// LiveProcess re-reads the process with the given identity from /proc and
+// returns its PID and owner
+func LiveProcess(id string) (pid, uid int, err error) {
+	pid, err = ParseProcessKey(id)
+	if err != nil {
+		return 0, 0, err
+	}
+	st, err := readProcStat("/proc", pid)
+	if err != nil || !sameProcess(id, st) {
+		return 0, 0, ErrProcessGone
+	}
+	uid, err = readOwner("/proc", pid)
+	if err != nil {
+		return 0, 0, ErrProcessGone
+	}
+	return pid, uid, nil
+}
+
+// processName is the name assigned to a process identity
+type processName struct {
+	name       string
+	workingDir string
+	alias      string
+}
+
+// processNamer keeps the name of every process for its lifetime. Names
+// come from the alias of the working directory, the git context or the
+// directory name, with an ordinal suffix only while another live process
+// holds the same name.
+type processNamer struct {
+	aliases map[string]string
+	names   map[string]processName
+}
+
+func newProcessNamer() *processNamer {
+	return &processNamer{
+		aliases: make(map[string]string),
+		names:   make(map[string]processName),
+	}
+}
+
+// setAliases replaces the aliases, keyed by working directory. Processes
+// whose alias changed are renamed on the next assign.
+func (n *processNamer) setAliases(aliases map[string]string) {
+	n.aliases = make(map[string]string, len(aliases))
+	for dir, alias := range aliases {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			n.aliases[filepath.Clean(dir)] = alias
+		}
+	}
+	for id, pn := range n.names {
+		if n.aliases[pn.workingDir] != pn.alias {
+			delete(n.names, id)
+		}
+	}
+}
+
+// assign sets the Name of processes, which must be sorted by start time,
+// and forgets processes that are gone
+func (n *processNamer) assign(processes []ClaudeProcess) {
+	live := make(map[string]bool, len(processes))
+	taken := make(map[string]bool, len(processes))
+	for _, p := range processes {
+		live[p.ID] = true
+		if pn, ok := n.names[p.ID]; ok {
+			taken[pn.name] = true
+		}
+	}
+	for id := range n.names {
+		if !live[id] {
+			delete(n.names, id)
+		}
+	}
+
+	for i := range processes {
+		p := &processes[i]
+		if pn, ok := n.names[p.ID]; ok {
+			p.Name = pn.name
+			continue
+		}
+
+		alias := n.aliases[p.WorkingDir]
+		base := p.Name
+		if alias != "" {
+			base = alias
+		}
+
+		name := base
+		for ordinal := 2; taken[name]; ordinal++ {
+			name = fmt.Sprintf("%s (%s)", base, getOrdinalSuffix(ordinal))
+		}
+		taken[name] = true
+
+		n.names[p.ID] = processName{name: name, workingDir: p.WorkingDir, alias: alias}
+		p.Name = name
+	}
+}
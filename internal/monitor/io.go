@@ -0,0 +1,147 @@
+package monitor
+
+import (
+	"path/filepath"
+	"strconv"
+)
+
+// IOStats holds cumulative I/O counters for a process and its descendants,
+// plus rates derived from the previous sample
+type IOStats struct {
+	ReadBytes           uint64  `json:"readBytes"`
+	WriteBytes          uint64  `json:"writeBytes"`
+	SyscR               uint64  `json:"syscr"`
+	SyscW               uint64  `json:"syscw"`
+	CancelledWriteBytes uint64  `json:"cancelledWriteBytes"`
+	ReadBytesPerSec     float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec    float64 `json:"writeBytesPerSec"`
+	SyscRPerSec         float64 `json:"syscrPerSec"`
+	SyscWPerSec         float64 `json:"syscwPerSec"`
+}
+
+type ioCounters struct {
+	readBytes           uint64
+	writeBytes          uint64
+	syscr               uint64
+	syscw               uint64
+	cancelledWriteBytes uint64
+}
+
+func (c *ioCounters) add(o ioCounters) {
+	c.readBytes += o.readBytes
+	c.writeBytes += o.writeBytes
+	c.syscr += o.syscr
+	c.syscw += o.syscw
+	c.cancelledWriteBytes += o.cancelledWriteBytes
+}
+
+// getIOCounters reads /proc/{pid}/io. The file is only readable by the
+// owner of the process (or root); zero counters are returned otherwise.
+func getIOCounters(pid int) ioCounters {
+	fields := readKBFields(filepath.Join("/proc", strconv.Itoa(pid), "io"))
+	return ioCounters{
+		readBytes:           fields["read_bytes"],
+		writeBytes:          fields["write_bytes"],
+		syscr:               fields["syscr"],
+		syscw:               fields["syscw"],
+		cancelledWriteBytes: fields["cancelled_write_bytes"],
+	}
+}
+
+// since returns the counts added after prev, 0 for counters that shrank
+func (c ioCounters) since(prev ioCounters) ioCounters {
+	return ioCounters{
+		readBytes:           subClamped(c.readBytes, prev.readBytes),
+		writeBytes:          subClamped(c.writeBytes, prev.writeBytes),
+		syscr:               subClamped(c.syscr, prev.syscr),
+		syscw:               subClamped(c.syscw, prev.syscw),
+		cancelledWriteBytes: subClamped(c.cancelledWriteBytes, prev.cancelledWriteBytes),
+	}
+}
+
+func subClamped(cur, prev uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+// ioSample is the I/O of one member of a process tree
+type ioSample struct {
+	counters ioCounters
+	// parent is the identity of the parent process
+	parent string
+}
+
+// getTreeIOCounters reads the I/O counters of pid and all its descendants,
+// keyed by process identity
+func getTreeIOCounters(tree processTree, pid int, bootID string) map[string]ioSample {
+	members := make(map[string]ioSample)
+	for _, p := range append([]int{pid}, tree.Descendants(pid)...) {
+		st, ok := tree.stats[p]
+		if !ok {
+			continue
+		}
+		s := ioSample{counters: getIOCounters(p)}
+		if parent, ok := tree.stats[st.ppid]; ok {
+			s.parent = processID(st.ppid, bootID, parent.starttime)
+		}
+		members[processID(p, bootID, st.starttime)] = s
+	}
+	return members
+}
+
+// newIOStats sums the counters of a process tree and derives rates from the
+// growth of each member since the previous sample, so that children exiting
+// do not hide the I/O of the others. Children started since then count from
+// zero. The kernel adds the counters of a child to its parent when it is
+// waited for; those last seen are taken off the closest surviving ancestor
+// so that they are not counted twice.
+func newIOStats(cur, prev map[string]ioSample, hasPrev bool, elapsed float64) IOStats {
+	reaped := make(map[string]ioCounters)
+	if hasPrev {
+		for id, p := range prev {
+			if _, ok := cur[id]; ok {
+				continue
+			}
+			ancestor := p.parent
+			for seen := 0; ancestor != "" && seen < len(prev); seen++ {
+				if _, ok := cur[ancestor]; ok {
+					c := reaped[ancestor]
+					c.add(p.counters)
+					reaped[ancestor] = c
+					break
+				}
+				ancestor = prev[ancestor].parent
+			}
+		}
+	}
+
+	var total, delta ioCounters
+	for id, s := range cur {
+		total.add(s.counters)
+		if !hasPrev {
+			continue
+		}
+		if p, ok := prev[id]; ok {
+			delta.add(s.counters.since(p.counters).since(reaped[id]))
+		} else {
+			delta.add(s.counters)
+		}
+	}
+
+	stats := IOStats{
+		ReadBytes:           total.readBytes,
+		WriteBytes:          total.writeBytes,
+		SyscR:               total.syscr,
+		SyscW:               total.syscw,
+		CancelledWriteBytes: total.cancelledWriteBytes,
+	}
+	if hasPrev {
+		stats.ReadBytesPerSec = float64(delta.readBytes) / elapsed
+		stats.WriteBytesPerSec = float64(delta.writeBytes) / elapsed
+		stats.SyscRPerSec = float64(delta.syscr) / elapsed
+		stats.SyscWPerSec = float64(delta.syscw) / elapsed
+	}
+	return stats
+}
@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MemoryInfo is a per-process memory breakdown. All sizes are in MB.
+type MemoryInfo struct {
+	RSSMB     float64 `json:"rssMb"`
+	PSSMB     float64 `json:"pssMb"`
+	USSMB     float64 `json:"ussMb"`
+	SwapMB    float64 `json:"swapMb"`
+	AnonMB    float64 `json:"anonMb"`
+	FileMB    float64 `json:"fileMb"`
+	ShmemMB   float64 `json:"shmemMb"`
+	PeakRSSMB float64 `json:"peakRssMb"`
+	HasRollup bool    `json:"hasRollup"`
+}
+
+// getMemoryInfo reads /proc/{pid}/status and /proc/{pid}/smaps_rollup.
+// smaps_rollup is only readable by the owner of the process (or root), so
+// PSS and USS stay zero when it cannot be read.
+func getMemoryInfo(pid int) (MemoryInfo, int) {
+	var info MemoryInfo
+	procDir := filepath.Join("/proc", strconv.Itoa(pid))
+
+	status := readKBFields(filepath.Join(procDir, "status"))
+	info.RSSMB = kbToMB(status["VmRSS"])
+	info.AnonMB = kbToMB(status["RssAnon"])
+	info.FileMB = kbToMB(status["RssFile"])
+	info.ShmemMB = kbToMB(status["RssShmem"])
+	info.PeakRSSMB = kbToMB(status["VmHWM"])
+	info.SwapMB = kbToMB(status["VmSwap"])
+	threads := int(status["Threads"])
+
+	if rollup := readKBFields(filepath.Join(procDir, "smaps_rollup")); len(rollup) > 0 {
+		info.HasRollup = true
+		info.PSSMB = kbToMB(rollup["Pss"])
+		info.USSMB = kbToMB(rollup["Private_Clean"] + rollup["Private_Dirty"])
+		info.SwapMB = kbToMB(rollup["Swap"])
+	}
+
+	// Kernel threads and processes without an mm have no VmRSS line
+	if info.RSSMB == 0 {
+		info.RSSMB = getMemoryMB(pid)
+	}
+
+	return info, threads
+}
+
+// readKBFields parses "Key:   value [kB]" lines as found in status,
+// smaps_rollup and meminfo. Values without a unit are returned unchanged.
+func readKBFields(path string) map[string]uint64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	fields := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		parts := strings.Fields(rest)
+		if len(parts) == 0 {
+			continue
+		}
+		val, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		fields[key] = val
+	}
+
+	return fields
+}
+
+func kbToMB(kb uint64) float64 {
+	return float64(kb) / 1024
+}
@@ -0,0 +1,359 @@
+package monitor
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Connection is a socket owned by a process
+type Connection struct {
+	PID           int    `json:"pid"`
+	Command       string `json:"command"`
+	Protocol      string `json:"protocol"`
+	State         string `json:"state"`
+	LocalAddress  string `json:"localAddress,omitempty"`
+	LocalPort     int    `json:"localPort,omitempty"`
+	RemoteAddress string `json:"remoteAddress,omitempty"`
+	RemotePort    int    `json:"remotePort,omitempty"`
+	Path          string `json:"path,omitempty"`
+	SocketType    string `json:"socketType,omitempty"`
+}
+
+// RemoteEndpoint is a remote address with the number of connections to it
+type RemoteEndpoint struct {
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	Count   int    `json:"count"`
+}
+
+// ProcessConnections is the socket summary of a process and its descendants
+type ProcessConnections struct {
+	PID         int              `json:"pid"`
+	Counts      map[string]int   `json:"counts"`
+	UnixCount   int              `json:"unixCount"`
+	Remotes     []RemoteEndpoint `json:"remotes"`
+	Listening   []Connection     `json:"listening"`
+	Connections []Connection     `json:"connections"`
+}
+
+// ConnectionCollector maps socket inodes of processes to the kernel socket
+// tables. ProcRoot can point at a fixture directory laid out like /proc.
+type ConnectionCollector struct {
+	ProcRoot string
+}
+
+// NewConnectionCollector creates a collector reading the live /proc
+func NewConnectionCollector() *ConnectionCollector {
+	return &ConnectionCollector{ProcRoot: "/proc"}
+}
+
+// tcpStates maps the hex state column of /proc/net/tcp to its name
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+var unixSocketTypes = map[string]string{
+	"0001": "stream",
+	"0002": "dgram",
+	"0005": "seqpacket",
+}
+
+// unixAcceptCon is __SO_ACCEPTCON, set in the flags of listening sockets
+const unixAcceptCon = 0x10000
+
+// Connections returns the sockets of pid and all its descendants
+func (c *ConnectionCollector) Connections(pid int) (*ProcessConnections, error) {
+	if _, err := os.Stat(filepath.Join(c.ProcRoot, strconv.Itoa(pid))); err != nil {
+		return nil, err
+	}
+
+	tree, err := readProcessTree(c.ProcRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map socket inodes to the first process of the tree holding them
+	owners := make(map[uint64]int)
+	pids := append([]int{pid}, tree.Descendants(pid)...)
+	for _, p := range pids {
+		for _, inode := range c.socketInodes(p) {
+			if _, ok := owners[inode]; !ok {
+				owners[inode] = p
+			}
+		}
+	}
+
+	// The socket tables of /proc/{pid}/net are those of the network
+	// namespace of pid, which differs from the monitor's in containers
+	var conns []Connection
+	for _, p := range c.namespacePIDs(pids) {
+		netDir := filepath.Join(c.ProcRoot, strconv.Itoa(p), "net")
+		for _, proto := range []string{"tcp", "tcp6"} {
+			conns = append(conns, readTCP(filepath.Join(netDir, proto), proto, owners)...)
+		}
+		conns = append(conns, readUnix(filepath.Join(netDir, "unix"), owners)...)
+	}
+
+	result := &ProcessConnections{
+		PID:         pid,
+		Counts:      make(map[string]int),
+		Listening:   []Connection{},
+		Connections: []Connection{},
+		Remotes:     []RemoteEndpoint{},
+	}
+
+	commands := make(map[int]string)
+	remotes := make(map[string]*RemoteEndpoint)
+	for _, conn := range conns {
+		if _, ok := commands[conn.PID]; !ok {
+			commands[conn.PID] = readComm(c.ProcRoot, conn.PID)
+		}
+		conn.Command = commands[conn.PID]
+
+		if conn.Protocol == "unix" {
+			result.UnixCount++
+		} else {
+			result.Counts[conn.State]++
+		}
+
+		if conn.State == "LISTEN" {
+			result.Listening = append(result.Listening, conn)
+			continue
+		}
+		result.Connections = append(result.Connections, conn)
+
+		if conn.RemoteAddress != "" && conn.RemotePort != 0 {
+			key := net.JoinHostPort(conn.RemoteAddress, strconv.Itoa(conn.RemotePort))
+			if r, ok := remotes[key]; ok {
+				r.Count++
+			} else {
+				remotes[key] = &RemoteEndpoint{Address: conn.RemoteAddress, Port: conn.RemotePort, Count: 1}
+			}
+		}
+	}
+
+	for _, r := range remotes {
+		result.Remotes = append(result.Remotes, *r)
+	}
+	sort.Slice(result.Remotes, func(i, j int) bool {
+		if result.Remotes[i].Count != result.Remotes[j].Count {
+			return result.Remotes[i].Count > result.Remotes[j].Count
+		}
+		return result.Remotes[i].Address < result.Remotes[j].Address
+	})
+
+	return result, nil
+}
+
+// namespacePIDs returns one of pids per network namespace. Processes whose
+// namespace cannot be read are assumed to share that of the first one.
+func (c *ConnectionCollector) namespacePIDs(pids []int) []int {
+	seen := make(map[string]bool)
+	var result []int
+	for i, p := range pids {
+		ns, err := os.Readlink(filepath.Join(c.ProcRoot, strconv.Itoa(p), "ns", "net"))
+		if err != nil {
+			if i > 0 {
+				continue
+			}
+			ns = ""
+		}
+		if !seen[ns] {
+			seen[ns] = true
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// socketInodes returns the inodes of all sockets open in /proc/{pid}/fd
+func (c *ConnectionCollector) socketInodes(pid int) []uint64 {
+	fdDir := filepath.Join(c.ProcRoot, strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil
+	}
+
+	var inodes []uint64
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if inode, ok := parseSocketLink(target); ok {
+			inodes = append(inodes, inode)
+		}
+	}
+	return inodes
+}
+
+// parseSocketLink extracts the inode from an fd link like "socket:[12345]"
+func parseSocketLink(target string) (uint64, bool) {
+	if !strings.HasPrefix(target, "socket:[") || !strings.HasSuffix(target, "]") {
+		return 0, false
+	}
+	inode, err := strconv.ParseUint(target[len("socket:["):len(target)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return inode, true
+}
+
+func readTCP(path, proto string, owners map[uint64]int) []Connection {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var conns []Connection
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Skip header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			continue
+		}
+		pid, ok := owners[inode]
+		if !ok {
+			continue
+		}
+
+		localAddr, localPort, err := parseHexAddress(fields[1])
+		if err != nil {
+			continue
+		}
+		remoteAddr, remotePort, err := parseHexAddress(fields[2])
+		if err != nil {
+			continue
+		}
+
+		state := tcpStates[fields[3]]
+		if state == "" {
+			state = "UNKNOWN"
+		}
+
+		conn := Connection{
+			PID:          pid,
+			Protocol:     proto,
+			State:        state,
+			LocalAddress: localAddr,
+			LocalPort:    localPort,
+		}
+		if state != "LISTEN" {
+			conn.RemoteAddress = remoteAddr
+			conn.RemotePort = remotePort
+		}
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+func readUnix(path string, owners map[uint64]int) []Connection {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var conns []Connection
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Skip header
+	for scanner.Scan() {
+		// Num RefCount Protocol Flags Type St Inode [Path]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 {
+			continue
+		}
+
+		inode, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			continue
+		}
+		pid, ok := owners[inode]
+		if !ok {
+			continue
+		}
+
+		flags, _ := strconv.ParseUint(fields[3], 16, 64)
+		state := "UNCONNECTED"
+		switch {
+		case flags&unixAcceptCon != 0:
+			state = "LISTEN"
+		case fields[5] == "03":
+			state = "CONNECTED"
+		case fields[5] == "02":
+			state = "CONNECTING"
+		}
+
+		conn := Connection{
+			PID:        pid,
+			Protocol:   "unix",
+			State:      state,
+			SocketType: unixSocketTypes[fields[4]],
+		}
+		if len(fields) > 7 {
+			conn.Path = fields[7]
+		}
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// parseHexAddress decodes "0100007F:1F90" style addresses from
+// /proc/net/tcp{,6}. Addresses are stored as 32-bit words in host byte
+// order, which is assumed to be little-endian.
+func parseHexAddress(s string) (string, int, error) {
+	hexIP, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("malformed address %q", s)
+	}
+
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", 0, err
+	}
+
+	raw, err := hex.DecodeString(hexIP)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, fmt.Errorf("malformed address %q", s)
+	}
+
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+
+	return ip.String(), int(port), nil
+}
+
+// readComm returns the command name of pid, or "" if it has exited
+func readComm(procRoot string, pid int) string {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
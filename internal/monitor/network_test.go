@@ -0,0 +1,75 @@
+package monitor
+
+import (
+	"testing"
+)
+
+// testdata/proc holds a session (100) with a child in another network
+// namespace (101), and an unrelated process (102) whose socket appears in
+// the session's table
+
+func TestConnectionsFixture(t *testing.T) {
+	c := &ConnectionCollector{ProcRoot: "testdata/proc"}
+	conns, err := c.Connections(100)
+	if err != nil {
+		t.Fatalf("Connections: %v", err)
+	}
+
+	if got := conns.Counts["LISTEN"]; got != 1 {
+		t.Errorf("LISTEN count = %d, want 1", got)
+	}
+	if got := conns.Counts["ESTABLISHED"]; got != 2 {
+		t.Errorf("ESTABLISHED count = %d, want 2", got)
+	}
+	if conns.UnixCount != 1 {
+		t.Errorf("UnixCount = %d, want 1", conns.UnixCount)
+	}
+
+	want := map[string]Connection{
+		"93.184.216.34": {PID: 100, Command: "claude", LocalAddress: "10.0.0.2", LocalPort: 40000, RemotePort: 443},
+		"140.82.112.3":  {PID: 101, Command: "node", LocalAddress: "172.17.0.2", LocalPort: 50000, RemotePort: 443},
+	}
+	if len(conns.Connections) != len(want) {
+		t.Fatalf("got %d connections, want %d: %+v", len(conns.Connections), len(want), conns.Connections)
+	}
+	for _, conn := range conns.Connections {
+		w, ok := want[conn.RemoteAddress]
+		if !ok {
+			t.Errorf("unexpected connection %+v", conn)
+			continue
+		}
+		if conn.PID != w.PID || conn.Command != w.Command || conn.LocalAddress != w.LocalAddress ||
+			conn.LocalPort != w.LocalPort || conn.RemotePort != w.RemotePort || conn.State != "ESTABLISHED" {
+			t.Errorf("connection to %s = %+v, want %+v", conn.RemoteAddress, conn, w)
+		}
+	}
+
+	if len(conns.Listening) != 2 {
+		t.Fatalf("got %d listening sockets, want 2: %+v", len(conns.Listening), conns.Listening)
+	}
+	for _, l := range conns.Listening {
+		switch l.Protocol {
+		case "tcp":
+			if l.LocalAddress != "127.0.0.1" || l.LocalPort != 8080 {
+				t.Errorf("tcp listener = %+v, want 127.0.0.1:8080", l)
+			}
+		case "unix":
+			if l.Path != "/run/claude.sock" || l.SocketType != "stream" {
+				t.Errorf("unix listener = %+v, want stream /run/claude.sock", l)
+			}
+		default:
+			t.Errorf("unexpected listener %+v", l)
+		}
+	}
+
+	if len(conns.Remotes) != 2 {
+		t.Errorf("got %d remotes, want 2: %+v", len(conns.Remotes), conns.Remotes)
+	}
+}
+
+func TestConnectionsMissingProcess(t *testing.T) {
+	c := &ConnectionCollector{ProcRoot: "testdata/proc"}
+	if _, err := c.Connections(999); err == nil {
+		t.Error("expected an error for a missing process")
+	}
+}
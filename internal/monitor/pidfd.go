@@ -0,0 +1,173 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// sysPidfdOpen is pidfd_open(2), which has the same number on every
+	// architecture
+	sysPidfdOpen = 434
+	// pPidfd is the idtype of waitid(2) for waiting on a pidfd
+	pPidfd = 3
+)
+
+// si_code values of SIGCHLD
+const (
+	cldExited = 1
+	cldKilled = 2
+	cldDumped = 3
+)
+
+type watchedProcess struct {
+	id string
+	fd int
+}
+
+// exitWatcher learns the exact exit time of processes by polling pidfds,
+// which become readable when the process exits. The exit status is only
+// available to the parent, i.e. when the process is a child of the
+// monitor.
+type exitWatcher struct {
+	mu       sync.Mutex
+	epfd     int
+	started  bool
+	disabled bool
+	byFD     map[int32]watchedProcess
+	byID     map[string]int32
+	onExit   func(id string, at time.Time, status *ExitStatus)
+}
+
+func newExitWatcher(onExit func(id string, at time.Time, status *ExitStatus)) *exitWatcher {
+	return &exitWatcher{
+		byFD:   make(map[int32]watchedProcess),
+		byID:   make(map[string]int32),
+		onExit: onExit,
+	}
+}
+
+// watch opens a pidfd for the process with the given identity. Kernels
+// without pidfd_open disable the watcher.
+func (w *exitWatcher) watch(id string, pid int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.disabled {
+		return
+	}
+	if _, ok := w.byID[id]; ok {
+		return
+	}
+	if !w.started {
+		epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+		if err != nil {
+			w.disabled = true
+			return
+		}
+		w.epfd = epfd
+		w.started = true
+		go w.loop()
+	}
+
+	r, _, errno := syscall.Syscall(sysPidfdOpen, uintptr(pid), 0, 0)
+	if errno != 0 {
+		if errno == syscall.ENOSYS {
+			w.disabled = true
+		}
+		return
+	}
+	fd := int(r)
+
+	// The PID may have been reused since the scan
+	st, err := readProcStat("/proc", pid)
+	if err != nil || !sameProcess(id, st) {
+		syscall.Close(fd)
+		return
+	}
+
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+	if err := syscall.EpollCtl(w.epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+		syscall.Close(fd)
+		return
+	}
+	w.byFD[int32(fd)] = watchedProcess{id: id, fd: fd}
+	w.byID[id] = int32(fd)
+}
+
+// forget stops watching a process
+func (w *exitWatcher) forget(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if fd, ok := w.byID[id]; ok {
+		w.closeLocked(fd)
+	}
+}
+
+func (w *exitWatcher) closeLocked(fd int32) {
+	wp := w.byFD[fd]
+	delete(w.byFD, fd)
+	delete(w.byID, wp.id)
+	syscall.EpollCtl(w.epfd, syscall.EPOLL_CTL_DEL, wp.fd, nil)
+	syscall.Close(wp.fd)
+}
+
+func (w *exitWatcher) loop() {
+	events := make([]syscall.EpollEvent, 16)
+	for {
+		n, err := syscall.EpollWait(w.epfd, events, -1)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		now := time.Now()
+		for _, event := range events[:n] {
+			w.mu.Lock()
+			wp, ok := w.byFD[event.Fd]
+			if !ok {
+				w.mu.Unlock()
+				continue
+			}
+			status := waitPidfd(wp.fd)
+			w.closeLocked(event.Fd)
+			w.mu.Unlock()
+
+			// The event log calls watch while holding its lock
+			w.onExit(wp.id, now, status)
+		}
+	}
+}
+
+// waitPidfd returns the exit status of an exited child without reaping
+// it, or nil if the process is not a child of the monitor
+func waitPidfd(fd int) *ExitStatus {
+	// siginfo_t: si_signo, si_errno, si_code, padding, si_pid, si_uid,
+	// si_status
+	var info [128]byte
+	_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPidfd, uintptr(fd),
+		uintptr(unsafe.Pointer(&info[0])), syscall.WEXITED|syscall.WNOHANG|syscall.WNOWAIT, 0, 0)
+	if errno != 0 || binary.NativeEndian.Uint32(info[16:]) == 0 {
+		return nil
+	}
+
+	code := binary.NativeEndian.Uint32(info[8:])
+	status := int(int32(binary.NativeEndian.Uint32(info[24:])))
+	switch code {
+	case cldExited:
+		return &ExitStatus{Code: &status, Source: ExitSourceWait}
+	case cldKilled, cldDumped:
+		return &ExitStatus{
+			Signal:     signalName(syscall.Signal(status)),
+			CoreDumped: code == cldDumped,
+			Source:     ExitSourceWait,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Battery is a system battery from /sys/class/power_supply
+type Battery struct {
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	CapacityPercent float64 `json:"capacityPercent"`
+	EnergyNowWh     float64 `json:"energyNowWh"`
+	EnergyFullWh    float64 `json:"energyFullWh"`
+	PowerNowW       float64 `json:"powerNowW"`
+}
+
+// PowerStatus is the battery and AC state of the machine
+type PowerStatus struct {
+	Timestamp  int64     `json:"timestamp"`
+	HasBattery bool      `json:"hasBattery"`
+	OnAC       bool      `json:"onAc"`
+	OnBattery  bool      `json:"onBattery"`
+	Batteries  []Battery `json:"batteries"`
+	// CapacityPercent is the combined charge of all batteries
+	CapacityPercent float64 `json:"capacityPercent"`
+	// DrainWatts is the total discharge rate while on battery
+	DrainWatts float64 `json:"drainWatts"`
+	// ClaudeDrainWatts is the estimated part of DrainWatts caused by
+	// Claude sessions, using EstimateMethod "rapl" or "cpu-share"
+	ClaudeDrainWatts     float64 `json:"claudeDrainWatts"`
+	EstimateMethod       string  `json:"estimateMethod,omitempty"`
+	TimeRemainingMinutes float64 `json:"timeRemainingMinutes,omitempty"`
+}
+
+// PowerMonitor reads batteries and AC adapters from /sys/class/power_supply
+type PowerMonitor struct {
+	mu        sync.Mutex
+	sysfsRoot string
+	last      PowerStatus
+}
+
+// NewPowerMonitor creates a monitor reading the live /sys
+func NewPowerMonitor() *PowerMonitor {
+	return &PowerMonitor{sysfsRoot: "/sys"}
+}
+
+// SetSysfsRoot changes the directory used in place of /sys
+func (m *PowerMonitor) SetSysfsRoot(root string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sysfsRoot = root
+}
+
+// Sample reads the power supplies. The drain caused by Claude is taken
+// from the RAPL power attributed to processes when available, and
+// otherwise estimated from claudeCPUShare, the percentage of busy CPU time
+// used by Claude.
+func (m *PowerMonitor) Sample(processes []ClaudeProcess, claudeCPUShare float64) PowerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := PowerStatus{
+		Timestamp: time.Now().Unix(),
+		Batteries: []Battery{},
+	}
+
+	dirs, _ := filepath.Glob(filepath.Join(m.sysfsRoot, "class", "power_supply", "*"))
+	sort.Strings(dirs)
+
+	var energyNow, energyFull float64
+	for _, dir := range dirs {
+		switch readTrimmed(filepath.Join(dir, "type")) {
+		case "Mains", "USB":
+			if readTrimmed(filepath.Join(dir, "online")) == "1" {
+				status.OnAC = true
+			}
+		case "Battery":
+			// Skip batteries of peripherals such as mice
+			if readTrimmed(filepath.Join(dir, "scope")) == "Device" {
+				continue
+			}
+			b := readBattery(dir)
+			status.Batteries = append(status.Batteries, b)
+			energyNow += b.EnergyNowWh
+			energyFull += b.EnergyFullWh
+			if b.Status == "Discharging" {
+				status.OnBattery = true
+				status.DrainWatts += b.PowerNowW
+			}
+		}
+	}
+
+	status.HasBattery = len(status.Batteries) > 0
+	if energyFull > 0 {
+		status.CapacityPercent = energyNow / energyFull * 100
+	} else if status.HasBattery {
+		for _, b := range status.Batteries {
+			status.CapacityPercent += b.CapacityPercent
+		}
+		status.CapacityPercent /= float64(len(status.Batteries))
+	}
+	if status.OnBattery && status.DrainWatts > 0 && energyNow > 0 {
+		status.TimeRemainingMinutes = energyNow / status.DrainWatts * 60
+	}
+
+	if status.OnBattery && status.DrainWatts > 0 {
+		var raplWatts float64
+		for _, p := range processes {
+			raplWatts += p.PowerWatts
+		}
+		if raplWatts > 0 {
+			status.ClaudeDrainWatts = raplWatts
+			status.EstimateMethod = "rapl"
+		} else {
+			status.ClaudeDrainWatts = status.DrainWatts * claudeCPUShare / 100
+			status.EstimateMethod = "cpu-share"
+		}
+		if status.ClaudeDrainWatts > status.DrainWatts {
+			status.ClaudeDrainWatts = status.DrainWatts
+		}
+	}
+
+	m.last = status
+	return status
+}
+
+// Last returns the result of the most recent sample
+func (m *PowerMonitor) Last() PowerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// readBattery reads a battery directory. Batteries report either energy
+// (µWh, µW) or charge (µAh, µA), the latter is converted using the voltage.
+func readBattery(dir string) Battery {
+	b := Battery{
+		Name:            filepath.Base(dir),
+		Status:          readTrimmed(filepath.Join(dir, "status")),
+		CapacityPercent: float64(readInt(filepath.Join(dir, "capacity"))),
+	}
+
+	read := func(name string) float64 {
+		return float64(readInt(filepath.Join(dir, name))) / 1e6
+	}
+
+	voltage := read("voltage_now")
+	if v := read("energy_now"); v > 0 {
+		b.EnergyNowWh = v
+		b.EnergyFullWh = read("energy_full")
+	} else {
+		b.EnergyNowWh = read("charge_now") * voltage
+		b.EnergyFullWh = read("charge_full") * voltage
+	}
+	if p := read("power_now"); p != 0 {
+		b.PowerNowW = p
+	} else {
+		b.PowerNowW = read("current_now") * voltage
+	}
+	// Some drivers report a negative rate while discharging
+	if b.PowerNowW < 0 {
+		b.PowerNowW = -b.PowerNowW
+	}
+
+	return b
+}
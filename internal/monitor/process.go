@@ -13,20 +13,78 @@ import (
 
 // ClaudeProcess represents a running Claude CLI process
 type ClaudeProcess struct {
-	PID        int     `json:"pid"`
-	Name       string  `json:"name"`
-	WorkingDir string  `json:"workingDir"`
+	PID int `json:"pid"`
+	// ID identifies the process across PID reuse and reboots
+	ID string `json:"id"`
+	// Name is the alias of the working directory, "repo@branch" inside a
+	// git working tree or the base name of the working directory. It is
+	// kept for the lifetime of the process.
+	Name       string   `json:"name"`
+	WorkingDir string   `json:"workingDir"`
+	Git        *GitInfo `json:"git,omitempty"`
+	// UID is the real user ID of the process and User its name, or the
+	// UID as a string if it is not in /etc/passwd
+	UID  int    `json:"uid"`
+	User string `json:"user"`
+	// Command is the parsed command line; sensitive values are removed
+	Command *CommandInfo `json:"command,omitempty"`
+	// Container is set for processes in a container, whose WorkingDir is a
+	// path inside the container
+	Container *ContainerInfo `json:"container,omitempty"`
+	// Terminal is the TTY and the tmux pane, screen session or terminal
+	// emulator the session runs in
+	Terminal *TerminalInfo `json:"terminal,omitempty"`
+	// CPUPercent is relative to one core and exceeds 100 when the
+	// process runs on several cores
 	CPUPercent float64 `json:"cpuPercent"`
-	MemoryMB   float64 `json:"memoryMb"`
-	StartTime  int64   `json:"startTime"`
+	// CPUPercentTotal is relative to the online cores of the machine
+	CPUPercentTotal float64 `json:"cpuPercentTotal"`
+	// CPUPercentQuota is relative to the cores the CPU quota of the
+	// session's cgroup allows, or to the online cores without a quota
+	CPUPercentQuota float64 `json:"cpuPercentQuota"`
+	// CPUSeconds is the CPU time of the process and its descendants
+	CPUSeconds float64    `json:"cpuSeconds"`
+	MemoryMB   float64    `json:"memoryMb"`
+	Memory     MemoryInfo `json:"memory"`
+	Threads    int        `json:"threads"`
+	IO         IOStats    `json:"io"`
+	FDCount    int        `json:"fdCount"`
+	FDLimit    uint64     `json:"fdLimit"`
+	// FDUsagePercent is the highest open-FD usage relative to
+	// RLIMIT_NOFILE across the process and its descendants
+	FDUsagePercent float64 `json:"fdUsagePercent"`
+	StartTime      int64   `json:"startTime"`
+	// EnergyJoules and PowerWatts are the CPU package energy attributed
+	// to the process tree, filled in by EnergyMonitor
+	EnergyJoules float64 `json:"energyJoules"`
+	PowerWatts   float64 `json:"powerWatts"`
 }
 
 // ProcessMonitor tracks Claude processes
 type ProcessMonitor struct {
 	mu           sync.RWMutex
-	prevCPUTimes map[int]cpuTime
+	prevCPUTimes map[string]cpuTime
+	prevIO       map[string]ioSample // By identity of sessions and descendants
 	prevSample   time.Time
 	clkTck       float64
+	bootTime     int64
+	bootID       string
+	git          *GitMonitor
+	namer        *processNamer
+	events       *EventLog
+	// sessionOf maps the PIDs of sessions and their descendants to the
+	// session identity, kept current by the process connector
+	sessionOf map[int]string
+	// commands caches the command line per identity, as it only changes
+	// on exec
+	commands map[string]*CommandInfo
+	// terminals caches the terminal per identity; nil if there is none
+	terminals map[string]*terminalTarget
+	tmux      *TmuxClient
+	users     *PasswdDB
+	// containers caches the container per identity; nil if there is none
+	containers map[string]*ContainerInfo
+	resolver   *ContainerResolver
 }
 
 type cpuTime struct {
@@ -36,11 +94,79 @@ type cpuTime struct {
 
 // NewProcessMonitor creates a new process monitor
 func NewProcessMonitor() *ProcessMonitor {
-	return &ProcessMonitor{
-		prevCPUTimes: make(map[int]cpuTime),
+	pm := &ProcessMonitor{
+		prevCPUTimes: make(map[string]cpuTime),
+		prevIO:       make(map[string]ioSample),
 		prevSample:   time.Now(),
-		clkTck:       100.0, // Default clock ticks per second on Linux
+		clkTck:       readClockTicks(),
+		bootID:       readBootID("/proc"),
+		git:          NewGitMonitor(),
+		namer:        newProcessNamer(),
+		events:       NewEventLog(),
+		sessionOf:    make(map[int]string),
+		commands:     make(map[string]*CommandInfo),
+		terminals:    make(map[string]*terminalTarget),
+		tmux:         NewTmuxClient(),
+		users:        NewPasswdDB(),
+		containers:   make(map[string]*ContainerInfo),
+		resolver:     NewContainerResolver(),
 	}
+
+	bootTime, err := readBootTime("/proc")
+	if err != nil {
+		// Fall back to the uptime, which is rounded to the second
+		bootTime = time.Now().Unix() - int64(getUptime())
+	}
+	pm.bootTime = bootTime
+
+	return pm
+}
+
+// Events returns the log of process starts and exits
+func (pm *ProcessMonitor) Events() *EventLog {
+	return pm.events
+}
+
+// Users returns the user database processes are labelled from
+func (pm *ProcessMonitor) Users() *PasswdDB {
+	return pm.users
+}
+
+// SetAliases sets the names of sessions by working directory. Running
+// sessions are renamed if their alias changed.
+func (pm *ProcessMonitor) SetAliases(aliases map[string]string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.namer.setAliases(aliases)
+}
+
+// SelectTmuxPane switches the tmux window of a session to its pane
+func (pm *ProcessMonitor) SelectTmuxPane(id string) error {
+	socket, paneID, err := pm.tmuxTarget(id)
+	if err != nil {
+		return err
+	}
+	return pm.tmux.SelectPane(socket, paneID)
+}
+
+// SendTmuxKeys types keys into the tmux pane of a session, see
+// TmuxClient.SendKeys
+func (pm *ProcessMonitor) SendTmuxKeys(id string, keys []string, literal bool) error {
+	socket, paneID, err := pm.tmuxTarget(id)
+	if err != nil {
+		return err
+	}
+	return pm.tmux.SendKeys(socket, paneID, keys, literal)
+}
+
+func (pm *ProcessMonitor) tmuxTarget(id string) (string, string, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	term := pm.terminals[id]
+	if term == nil || term.socket == "" || term.paneID == "" {
+		return "", "", ErrNoTmuxPane
+	}
+	return term.socket, term.paneID, nil
 }
 
 // GetProcesses returns all running Claude processes
@@ -60,8 +186,9 @@ func (pm *ProcessMonitor) GetProcesses() ([]ClaudeProcess, error) {
 	}
 
 	var processes []ClaudeProcess
-	currentCPUTimes := make(map[int]cpuTime)
-	nameCount := make(map[string]int)
+	currentCPUTimes := make(map[string]cpuTime)
+	currentIO := make(map[string]ioSample)
+	zombies := make(map[string]int)
 
 	// First pass: collect all Claude processes
 	var rawProcesses []struct {
@@ -86,24 +213,74 @@ func (pm *ProcessMonitor) GetProcesses() ([]ClaudeProcess, error) {
 
 		proc := ClaudeProcess{PID: pid}
 
-		// Get working directory
+		// Get memory usage
+		proc.Memory, proc.Threads = getMemoryInfo(pid)
+		proc.MemoryMB = proc.Memory.RSSMB
+
+		// Get CPU times and start time
+		st, err := readProcStat("/proc", pid)
+		if err != nil {
+			continue
+		}
+		proc.ID = processID(pid, pm.bootID, st.starttime)
+		if uid, err := readOwner("/proc", pid); err == nil {
+			proc.UID = uid
+			proc.User = pm.users.Name(uid)
+		}
+		if st.state == 'Z' {
+			// Exited but not reaped yet, only the exit code is left
+			zombies[proc.ID] = st.exitCode
+			continue
+		}
+		ct := cpuTime{utime: st.utime, stime: st.stime}
+
+		// Get working directory, which inside a container is a path in the
+		// container's mount namespace
 		cwdPath := filepath.Join("/proc", entry.Name(), "cwd")
-		if cwd, err := os.Readlink(cwdPath); err == nil {
+		cwd, cwdErr := os.Readlink(cwdPath)
+		container, ok := pm.containers[proc.ID]
+		if !ok {
+			container = pm.resolver.Resolve(pid, cwd)
+			pm.containers[proc.ID] = container
+		}
+		if container != nil {
+			proc.Container = pm.resolver.withName(container)
+		}
+		if cwdErr == nil {
 			proc.WorkingDir = cwd
 			proc.Name = filepath.Base(cwd)
+			gitDir := cwd
+			if container != nil {
+				gitDir = container.HostWorkingDir
+				if gitDir == "" {
+					gitDir = filepath.Join("/proc", entry.Name(), "root", cwd)
+				}
+			}
+			if proc.Git = pm.git.Lookup(gitDir); proc.Git != nil {
+				proc.Name = proc.Git.SessionName()
+			}
 		} else {
 			proc.Name = "claude"
 		}
 
-		// Get memory usage
-		proc.MemoryMB = getMemoryMB(pid)
-
-		// Get CPU times
-		ct := getCPUTime(pid)
-		currentCPUTimes[pid] = ct
+		cmd, ok := pm.commands[proc.ID]
+		if !ok {
+			if cmd, err = readCommandInfo("/proc", pid); err == nil {
+				pm.commands[proc.ID] = cmd
+			}
+		}
+		proc.Command = cmd
 
-		// Get start time
-		proc.StartTime = getStartTime(pid)
+		term, ok := pm.terminals[proc.ID]
+		if !ok {
+			term = readTerminal("/proc", pid, st)
+			pm.terminals[proc.ID] = term
+		}
+		if term != nil {
+			proc.Terminal = term.resolve(pm.tmux)
+		}
+		currentCPUTimes[proc.ID] = ct
+		proc.StartTime = pm.bootTime + int64(float64(st.starttime)/pm.clkTck)
 
 		rawProcesses = append(rawProcesses, struct {
 			proc    ClaudeProcess
@@ -111,54 +288,178 @@ func (pm *ProcessMonitor) GetProcesses() ([]ClaudeProcess, error) {
 		}{proc, ct})
 	}
 
+	// Descendants are needed to attribute I/O of child processes
+	var tree processTree
+	if len(rawProcesses) > 0 {
+		tree, err = readProcessTree("/proc")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sessionOf := make(map[int]string)
+	for _, rp := range rawProcesses {
+		sessionOf[rp.proc.PID] = rp.proc.ID
+		for _, child := range tree.Descendants(rp.proc.PID) {
+			sessionOf[child] = rp.proc.ID
+		}
+	}
+	pm.sessionOf = sessionOf
+
 	// Sort by start time for consistent naming
 	sort.Slice(rawProcesses, func(i, j int) bool {
 		return rawProcesses[i].proc.StartTime < rawProcesses[j].proc.StartTime
 	})
 
-	// Second pass: calculate CPU% and assign names
+	// Second pass: calculate CPU%
 	for _, rp := range rawProcesses {
 		proc := rp.proc
 		ct := rp.cpuTime
 
 		// Calculate CPU percentage
-		if prev, ok := pm.prevCPUTimes[proc.PID]; ok {
-			totalDelta := float64((ct.utime - prev.utime) + (ct.stime - prev.stime))
+		if prev, ok := pm.prevCPUTimes[proc.ID]; ok {
+			totalDelta := float64(subClamped(ct.utime, prev.utime) + subClamped(ct.stime, prev.stime))
 			proc.CPUPercent = (totalDelta / pm.clkTck / elapsed) * 100.0
-			if proc.CPUPercent < 0 {
-				proc.CPUPercent = 0
+			if cores := onlineCPUs(); cores > 0 {
+				proc.CPUPercentTotal = proc.CPUPercent / float64(cores)
 			}
-			if proc.CPUPercent > 100 {
-				proc.CPUPercent = 100
+			if cores := availableCPUs(proc.PID); cores > 0 {
+				proc.CPUPercentQuota = proc.CPUPercent / cores
 			}
 		}
 
-		// Handle duplicate names
-		baseName := proc.Name
-		nameCount[baseName]++
-		if nameCount[baseName] > 1 {
-			ordinal := nameCount[baseName]
-			suffix := getOrdinalSuffix(ordinal)
-			proc.Name = fmt.Sprintf("%s (%s)", baseName, suffix)
+		proc.CPUSeconds = float64(tree.CPUTicks(proc.PID)) / pm.clkTck
+
+		// Calculate I/O rates for the whole process tree
+		io := getTreeIOCounters(tree, proc.PID, pm.bootID)
+		for id, c := range io {
+			currentIO[id] = c
+		}
+		_, hasPrevIO := pm.prevIO[proc.ID]
+		proc.IO = newIOStats(io, pm.prevIO, hasPrevIO, elapsed)
+
+		// Check file descriptor usage, which is usually exhausted by
+		// watcher-heavy child processes rather than Claude itself
+		proc.FDCount = countFDs("/proc", proc.PID)
+		proc.FDLimit, _ = readFDLimit("/proc", proc.PID)
+		proc.FDUsagePercent = fdUsagePercent("/proc", proc.PID)
+		for _, child := range tree.Descendants(proc.PID) {
+			if usage := fdUsagePercent("/proc", child); usage > proc.FDUsagePercent {
+				proc.FDUsagePercent = usage
+			}
 		}
 
 		processes = append(processes, proc)
 	}
 
+	// Older sessions keep their names when others start or exit
+	pm.namer.assign(processes)
+	pm.events.observe(now, processes, zombies)
+
 	// Update state
 	pm.prevCPUTimes = currentCPUTimes
+	pm.prevIO = currentIO
 	pm.prevSample = now
 
 	// Clean up old entries
-	for pid := range pm.prevCPUTimes {
-		if _, ok := currentCPUTimes[pid]; !ok {
-			delete(pm.prevCPUTimes, pid)
+	for id := range pm.prevCPUTimes {
+		if _, ok := currentCPUTimes[id]; !ok {
+			delete(pm.prevCPUTimes, id)
+		}
+	}
+	for id := range pm.commands {
+		if _, ok := currentCPUTimes[id]; !ok {
+			delete(pm.commands, id)
+		}
+	}
+	for id := range pm.terminals {
+		if _, ok := currentCPUTimes[id]; !ok {
+			delete(pm.terminals, id)
+		}
+	}
+	for id := range pm.containers {
+		if _, ok := currentCPUTimes[id]; !ok {
+			delete(pm.containers, id)
 		}
 	}
 
 	return processes, nil
 }
 
+// StartProcConnector subscribes to the netlink process connector so that
+// sessions are logged the moment they start and exit, and processes they
+// spawn are counted. Scans of /proc continue to provide the metrics and
+// remain the only source if this fails. The returned channel receives the
+// error that stopped the connector.
+func (pm *ProcessMonitor) StartProcConnector() (<-chan error, error) {
+	c, err := NewProcConnector()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := c.Run(pm.handleProcEvent)
+		c.Close()
+		done <- err
+	}()
+	return done, nil
+}
+
+// handleProcEvent applies a process connector event. Thread events, where
+// the PID differs from the thread group ID, are ignored.
+func (pm *ProcessMonitor) handleProcEvent(e ProcEvent) {
+	if e.PID != e.TGID {
+		return
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	switch e.Type {
+	case procEventFork:
+		if id, ok := pm.sessionOf[e.ParentTGID]; ok {
+			pm.sessionOf[e.PID] = id
+			pm.events.noteSpawn(id)
+		}
+
+	case procEventExec:
+		if !isClaude(e.PID) {
+			return
+		}
+		st, err := readProcStat("/proc", e.PID)
+		if err != nil {
+			return
+		}
+		proc := ClaudeProcess{
+			PID:       e.PID,
+			ID:        processID(e.PID, pm.bootID, st.starttime),
+			Name:      "claude",
+			StartTime: pm.bootTime + int64(float64(st.starttime)/pm.clkTck),
+		}
+		if cwd, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(e.PID), "cwd")); err == nil {
+			proc.WorkingDir = cwd
+			proc.Name = filepath.Base(cwd)
+		}
+		if uid, err := readOwner("/proc", e.PID); err == nil {
+			proc.UID = uid
+			proc.User = pm.users.Name(uid)
+		}
+		pm.sessionOf[e.PID] = proc.ID
+		pm.events.start(proc)
+
+	case procEventExit:
+		id, ok := pm.sessionOf[e.PID]
+		if !ok {
+			return
+		}
+		delete(pm.sessionOf, e.PID)
+		if strings.HasPrefix(id, strconv.Itoa(e.PID)+"-") {
+			pm.events.exit(id, e.Timestamp, waitStatus(e.ExitCode, ExitSourceNetlink))
+		}
+	}
+}
+
 func isClaude(pid int) bool {
 	// Check /proc/{pid}/comm for process name
 	commPath := filepath.Join("/proc", strconv.Itoa(pid), "comm")
@@ -194,73 +495,20 @@ func getMemoryMB(pid int) float64 {
 	return float64(rss*pageSize) / (1024 * 1024)
 }
 
-func getCPUTime(pid int) cpuTime {
-	statPath := filepath.Join("/proc", strconv.Itoa(pid), "stat")
-	data, err := os.ReadFile(statPath)
-	if err != nil {
-		return cpuTime{}
-	}
-
-	// Find the closing parenthesis for the command name
-	content := string(data)
-	idx := strings.LastIndex(content, ")")
-	if idx == -1 || idx+2 >= len(content) {
-		return cpuTime{}
-	}
-
-	fields := strings.Fields(content[idx+2:])
-	if len(fields) < 12 {
-		return cpuTime{}
-	}
-
-	// Fields 11 and 12 (0-indexed) are utime and stime
-	utime, _ := strconv.ParseUint(fields[11], 10, 64)
-	stime, _ := strconv.ParseUint(fields[12], 10, 64)
-
-	return cpuTime{utime: utime, stime: stime}
-}
-
-func getStartTime(pid int) int64 {
-	statPath := filepath.Join("/proc", strconv.Itoa(pid), "stat")
-	data, err := os.ReadFile(statPath)
+// getUptime returns the system uptime in seconds
+func getUptime() float64 {
+	data, err := os.ReadFile("/proc/uptime")
 	if err != nil {
 		return 0
 	}
 
-	content := string(data)
-	idx := strings.LastIndex(content, ")")
-	if idx == -1 || idx+2 >= len(content) {
-		return 0
-	}
-
-	fields := strings.Fields(content[idx+2:])
-	if len(fields) < 20 {
-		return 0
-	}
-
-	// Field 19 (0-indexed) is starttime in clock ticks since boot
-	starttime, _ := strconv.ParseUint(fields[19], 10, 64)
-
-	// Get boot time
-	uptimeData, err := os.ReadFile("/proc/uptime")
-	if err != nil {
-		return 0
-	}
-
-	uptimeFields := strings.Fields(string(uptimeData))
-	if len(uptimeFields) < 1 {
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
 		return 0
 	}
 
-	uptime, _ := strconv.ParseFloat(uptimeFields[0], 64)
-	now := time.Now().Unix()
-	bootTime := now - int64(uptime)
-
-	// Convert starttime from clock ticks to seconds
-	clkTck := 100.0 // sysconf(_SC_CLK_TCK), usually 100 on Linux
-	startSeconds := float64(starttime) / clkTck
-
-	return bootTime + int64(startSeconds)
+	uptime, _ := strconv.ParseFloat(fields[0], 64)
+	return uptime
 }
 
 func getOrdinalSuffix(n int) string {
@@ -0,0 +1,367 @@
+package monitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// projectRetentionDays is how long daily project totals are kept
+	projectRetentionDays = 90
+	// projectSaveInterval is how often the daily totals are written
+	projectSaveInterval = time.Minute
+	// projectKeyTTL is how long the project of a directory is cached
+	projectKeyTTL = 10 * time.Minute
+	// dayFormat is the local date of daily totals
+	dayFormat = "2006-01-02"
+)
+
+// ProjectRule names the project of all working directories below Prefix,
+// overriding the git repository
+type ProjectRule struct {
+	Prefix string `json:"prefix"`
+	Name   string `json:"name"`
+}
+
+// Project is the live total of the sessions of one project
+type Project struct {
+	// Key identifies the project: the rule name, the git directory shared
+	// by all worktrees of a repository, or the working directory
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	// Dirs are the working directories of the running sessions
+	Dirs       []string `json:"dirs"`
+	Sessions   int      `json:"sessions"`
+	CPUPercent float64  `json:"cpuPercent"`
+	MemoryMB   float64  `json:"memoryMb"`
+	// CPUSeconds is the CPU time of the running sessions
+	CPUSeconds float64 `json:"cpuSeconds"`
+	// Today is the total of the current day, including exited sessions
+	Today     ProjectDay `json:"today"`
+	Processes []string   `json:"processes"`
+}
+
+// ProjectDay is the CPU time and tokens of a project on one day
+type ProjectDay struct {
+	Date       string     `json:"date,omitempty"`
+	Key        string     `json:"key,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	CPUSeconds float64    `json:"cpuSeconds"`
+	Tokens     TokenCount `json:"tokens"`
+}
+
+type projectKey struct {
+	key  string
+	name string
+	at   time.Time
+}
+
+// projectState is the file the daily totals and transcript positions are
+// saved to
+type projectState struct {
+	Days    map[string]map[string]*ProjectDay `json:"days"`
+	Offsets map[string]int64                  `json:"offsets"`
+}
+
+// ProjectTracker groups sessions into projects and accumulates the CPU
+// time and tokens of each project per day, across monitor restarts
+type ProjectTracker struct {
+	mu        sync.Mutex
+	rules     []ProjectRule
+	users     *PasswdDB
+	statePath string
+	lastSave  time.Time
+
+	days        map[string]map[string]*ProjectDay
+	prevCPU     map[string]float64
+	keys        map[string]projectKey
+	configDirs  map[string]time.Time
+	transcripts *transcriptReader
+}
+
+// NewProjectTracker creates a tracker that finds the Claude config dirs of
+// session owners in users
+func NewProjectTracker(users *PasswdDB) *ProjectTracker {
+	return &ProjectTracker{
+		users:       users,
+		days:        make(map[string]map[string]*ProjectDay),
+		prevCPU:     make(map[string]float64),
+		keys:        make(map[string]projectKey),
+		configDirs:  make(map[string]time.Time),
+		transcripts: newTranscriptReader(),
+	}
+}
+
+// SetRules sets the prefix rules, longest prefix first
+func (t *ProjectTracker) SetRules(rules []ProjectRule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rules = make([]ProjectRule, 0, len(rules))
+	for _, r := range rules {
+		if r.Prefix != "" && r.Name != "" {
+			t.rules = append(t.rules, ProjectRule{Prefix: filepath.Clean(r.Prefix), Name: r.Name})
+		}
+	}
+	sort.SliceStable(t.rules, func(i, j int) bool {
+		return len(t.rules[i].Prefix) > len(t.rules[j].Prefix)
+	})
+	t.keys = make(map[string]projectKey)
+}
+
+// Load reads the totals saved at path and saves them there from now on
+func (t *ProjectTracker) Load(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.statePath = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var state projectState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	for date, projects := range state.Days {
+		if projects != nil {
+			t.days[date] = projects
+		}
+	}
+	for file, offset := range state.Offsets {
+		t.transcripts.offsets[file] = offset
+	}
+	return nil
+}
+
+// Save writes the totals to the path given to Load
+func (t *ProjectTracker) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.saveLocked()
+}
+
+func (t *ProjectTracker) saveLocked() error {
+	if t.statePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(projectState{Days: t.days, Offsets: t.transcripts.offsets})
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(t.statePath), 0755)
+	tmp := t.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	t.lastSave = time.Now()
+	return os.Rename(tmp, t.statePath)
+}
+
+// Sample adds the CPU time since the last sample and new transcript usage
+// to today's totals and returns the live projects, busiest first
+func (t *ProjectTracker) Sample(processes []ClaudeProcess) []Project {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	today := now.Format(dayFormat)
+	for dir, k := range t.keys {
+		if now.Sub(k.at) > projectKeyTTL {
+			delete(t.keys, dir)
+		}
+	}
+
+	byKey := make(map[string]*Project)
+	var projects []*Project
+	currentCPU := make(map[string]float64, len(processes))
+	for _, p := range processes {
+		key, name := t.projectOf(p.WorkingDir, p.Git, p.Container, now)
+		proj, ok := byKey[key]
+		if !ok {
+			proj = &Project{Key: key, Name: name, Dirs: []string{}, Processes: []string{}}
+			byKey[key] = proj
+			projects = append(projects, proj)
+		}
+		proj.Sessions++
+		proj.CPUPercent += p.CPUPercent
+		proj.MemoryMB += p.MemoryMB
+		proj.CPUSeconds += p.CPUSeconds
+		proj.Processes = append(proj.Processes, p.ID)
+		if !slices.Contains(proj.Dirs, p.WorkingDir) {
+			proj.Dirs = append(proj.Dirs, p.WorkingDir)
+		}
+
+		// Sessions already running at the first sample only count from
+		// then on
+		currentCPU[p.ID] = p.CPUSeconds
+		if prev, ok := t.prevCPU[p.ID]; ok && p.CPUSeconds > prev {
+			t.day(today, key, name).CPUSeconds += p.CPUSeconds - prev
+		}
+
+		if p.Container == nil {
+			if dir := t.configDir(p); dir != "" {
+				t.configDirs[dir] = now
+			}
+		}
+	}
+	t.prevCPU = currentCPU
+
+	// Transcripts of exited sessions are followed for another day
+	dirs := make([]string, 0, len(t.configDirs))
+	for dir, seen := range t.configDirs {
+		if now.Sub(seen) > 24*time.Hour {
+			delete(t.configDirs, dir)
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	notBefore := now.AddDate(0, 0, -projectRetentionDays)
+	for _, u := range t.transcripts.read(dirs, notBefore) {
+		if u.at.Before(notBefore) {
+			continue
+		}
+		key, name := t.projectOf(u.cwd, nil, nil, now)
+		t.day(u.at.Local().Format(dayFormat), key, name).Tokens.Add(u.tokens)
+	}
+
+	for date := range t.days {
+		if d, err := time.ParseInLocation(dayFormat, date, time.Local); err != nil || d.Before(notBefore) {
+			delete(t.days, date)
+		}
+	}
+	if now.Sub(t.lastSave) > projectSaveInterval {
+		t.saveLocked()
+	}
+
+	result := make([]Project, 0, len(projects))
+	for _, proj := range projects {
+		if day, ok := t.days[today][proj.Key]; ok {
+			proj.Today = ProjectDay{CPUSeconds: day.CPUSeconds, Tokens: day.Tokens}
+		}
+		result = append(result, *proj)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CPUPercent > result[j].CPUPercent
+	})
+	return result
+}
+
+// Days returns the daily totals since the given date, oldest first
+func (t *ProjectTracker) Days(since time.Time) []ProjectDay {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	first := since.Format(dayFormat)
+	days := []ProjectDay{}
+	for date, projects := range t.days {
+		if date < first {
+			continue
+		}
+		for key, day := range projects {
+			d := *day
+			d.Date, d.Key = date, key
+			days = append(days, d)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		if days[i].Date != days[j].Date {
+			return days[i].Date < days[j].Date
+		}
+		return days[i].CPUSeconds > days[j].CPUSeconds
+	})
+	return days
+}
+
+// SumProjectDays totals daily values per project, most CPU time first
+func SumProjectDays(days []ProjectDay) []ProjectDay {
+	byKey := make(map[string]*ProjectDay)
+	var totals []*ProjectDay
+	for _, d := range days {
+		total, ok := byKey[d.Key]
+		if !ok {
+			total = &ProjectDay{Key: d.Key}
+			byKey[d.Key] = total
+			totals = append(totals, total)
+		}
+		total.Name = d.Name // The latest name wins
+		total.CPUSeconds += d.CPUSeconds
+		total.Tokens.Add(d.Tokens)
+	}
+
+	result := make([]ProjectDay, 0, len(totals))
+	for _, total := range totals {
+		result = append(result, *total)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CPUSeconds > result[j].CPUSeconds
+	})
+	return result
+}
+
+func (t *ProjectTracker) day(date, key, name string) *ProjectDay {
+	projects, ok := t.days[date]
+	if !ok {
+		projects = make(map[string]*ProjectDay)
+		t.days[date] = projects
+	}
+	day, ok := projects[key]
+	if !ok {
+		day = &ProjectDay{}
+		projects[key] = day
+	}
+	day.Name = name
+	return day
+}
+
+// projectOf returns the key and name of the project of a working
+// directory: the first matching rule, else the git repository shared by
+// all its worktrees, else the directory itself
+func (t *ProjectTracker) projectOf(dir string, git *GitInfo, container *ContainerInfo, now time.Time) (string, string) {
+	path := dir
+	if container != nil && container.HostWorkingDir != "" {
+		path = container.HostWorkingDir
+	}
+	for _, r := range t.rules {
+		if isWithin(path, r.Prefix) {
+			return "rule:" + r.Name, r.Name
+		}
+	}
+	if git != nil && git.commonDir != "" {
+		return git.commonDir, git.Repo
+	}
+	if container != nil {
+		return path, filepath.Base(path)
+	}
+
+	if k, ok := t.keys[path]; ok {
+		return k.key, k.name
+	}
+	k := projectKey{key: path, name: filepath.Base(path), at: now}
+	if _, gitDir, ok := findGitDir(path); ok {
+		common := gitCommonDir(gitDir)
+		k.key, k.name = common, gitRepoName(common)
+	}
+	t.keys[path] = k
+	return k.key, k.name
+}
+
+// configDir returns the Claude config dir of a session, where its
+// transcripts are written
+func (t *ProjectTracker) configDir(p ClaudeProcess) string {
+	if p.Command != nil {
+		if dir := p.Command.Env["CLAUDE_CONFIG_DIR"]; dir != "" {
+			return dir
+		}
+	}
+	if home := t.users.Home(p.UID); home != "" {
+		return filepath.Join(home, ".claude")
+	}
+	return ""
+}
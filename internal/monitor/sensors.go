@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Sensor types
+const (
+	SensorTemperature = "temperature"
+	SensorFan         = "fan"
+	SensorVoltage     = "voltage"
+	SensorCurrent     = "current"
+	SensorPower       = "power"
+)
+
+// Sensor is a hardware monitoring reading. Value, Min, Max and Crit are in
+// Unit: °C, RPM, V, A or W.
+type Sensor struct {
+	Chip  string  `json:"chip"`
+	Label string  `json:"label"`
+	Type  string  `json:"type"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+	// Min, Max and Crit are nil if the chip reports no such limit
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	Crit  *float64 `json:"crit,omitempty"`
+	Alarm bool     `json:"alarm,omitempty"`
+}
+
+// ID identifies a sensor as "chip/label"
+func (s Sensor) ID() string {
+	return s.Chip + "/" + s.Label
+}
+
+// SensorChip is a group of sensors on the same chip
+type SensorChip struct {
+	Chip    string   `json:"chip"`
+	Sensors []Sensor `json:"sensors"`
+}
+
+// sensorKind describes a hwmon attribute prefix
+type sensorKind struct {
+	typ  string
+	unit string
+	// scale converts the sysfs value to unit; sensors -u already prints
+	// values in unit
+	scale float64
+}
+
+var sensorKinds = map[string]sensorKind{
+	"temp":  {SensorTemperature, "°C", 1e3},
+	"fan":   {SensorFan, "RPM", 1},
+	"in":    {SensorVoltage, "V", 1e3},
+	"curr":  {SensorCurrent, "A", 1e3},
+	"power": {SensorPower, "W", 1e6},
+}
+
+// GroupSensorsByChip groups sensors by chip, keeping their order
+func GroupSensorsByChip(sensors []Sensor) []SensorChip {
+	var chips []SensorChip
+	index := make(map[string]int)
+	for _, s := range sensors {
+		i, ok := index[s.Chip]
+		if !ok {
+			i = len(chips)
+			index[s.Chip] = i
+			chips = append(chips, SensorChip{Chip: s.Chip})
+		}
+		chips[i].Sensors = append(chips[i].Sensors, s)
+	}
+	return chips
+}
+
+// temperaturesFromSensors returns the temperature sensors as Temperature
+func temperaturesFromSensors(sensors []Sensor) []Temperature {
+	var temps []Temperature
+	for _, s := range sensors {
+		if s.Type != SensorTemperature {
+			continue
+		}
+		temps = append(temps, Temperature{
+			Chip:    s.Chip,
+			Label:   s.Label,
+			Current: s.Value,
+			High:    s.Max,
+			Crit:    s.Crit,
+			Alarm:   s.Alarm,
+		})
+	}
+	return temps
+}
+
+// Patterns for parsing sensors -u output
+var (
+	sensorsChipPattern    = regexp.MustCompile(`^(\S[^:]*)$`)
+	sensorsLabelPattern   = regexp.MustCompile(`^([^:]+):$`)
+	sensorsFeaturePattern = regexp.MustCompile(`^\s+(temp|fan|in|curr|power)\d+_(\w+):\s+([-\d.]+)$`)
+)
+
+func parseSensorsOutput(output string) []Sensor {
+	var sensors []Sensor
+
+	var current *Sensor
+	hasInput := false
+	flush := func() {
+		if current != nil && hasInput {
+			sensors = append(sensors, *current)
+		}
+		current = nil
+		hasInput = false
+	}
+
+	var currentChip string
+	for _, line := range strings.Split(output, "\n") {
+		// Check for chip line, which starts a new block
+		if matches := sensorsChipPattern.FindStringSubmatch(line); len(matches) > 1 {
+			flush()
+			currentChip = matches[1]
+			continue
+		}
+
+		// Check for label line
+		if matches := sensorsLabelPattern.FindStringSubmatch(line); len(matches) > 1 {
+			flush()
+			current = &Sensor{Chip: currentChip, Label: matches[1]}
+			continue
+		}
+
+		matches := sensorsFeaturePattern.FindStringSubmatch(line)
+		if matches == nil || current == nil {
+			continue
+		}
+		kind := sensorKinds[matches[1]]
+		current.Type = kind.typ
+		current.Unit = kind.unit
+		val, err := strconv.ParseFloat(matches[3], 64)
+		if err != nil {
+			continue
+		}
+
+		switch matches[2] {
+		case "input", "average":
+			// Sanity check, some chips report garbage temperatures
+			if kind.typ == SensorTemperature && (val <= 0 || val >= 150) {
+				continue
+			}
+			if !hasInput || matches[2] == "average" {
+				current.Value = val
+			}
+			hasInput = true
+		case "min":
+			current.Min = &val
+		case "max", "cap":
+			current.Max = &val
+		case "crit":
+			current.Crit = &val
+		case "alarm", "crit_alarm":
+			current.Alarm = current.Alarm || val != 0
+		}
+	}
+
+	// Don't forget the last one
+	flush()
+
+	return sensors
+}
@@ -0,0 +1,289 @@
+package monitor
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CoreUsage is the utilization of one logical CPU since the previous sample
+type CoreUsage struct {
+	CPU     int     `json:"cpu"`
+	Percent float64 `json:"percent"`
+}
+
+// SystemMemory is the memory and swap usage from /proc/meminfo, in MB
+type SystemMemory struct {
+	TotalMB         float64 `json:"totalMb"`
+	AvailableMB     float64 `json:"availableMb"`
+	UsedMB          float64 `json:"usedMb"`
+	UsedPercent     float64 `json:"usedPercent"`
+	BuffersMB       float64 `json:"buffersMb"`
+	CachedMB        float64 `json:"cachedMb"`
+	SwapTotalMB     float64 `json:"swapTotalMb"`
+	SwapUsedMB      float64 `json:"swapUsedMb"`
+	SwapUsedPercent float64 `json:"swapUsedPercent"`
+}
+
+// PressureLine is one line of a /proc/pressure file. Averages are the
+// percentage of time stalled; Total is in microseconds.
+type PressureLine struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"`
+}
+
+// Pressure is the pressure stall information of one resource. Full is not
+// reported for CPU on older kernels.
+type Pressure struct {
+	Some PressureLine  `json:"some"`
+	Full *PressureLine `json:"full,omitempty"`
+}
+
+// SystemStatus is a snapshot of machine-wide load
+type SystemStatus struct {
+	Timestamp    int64        `json:"timestamp"`
+	CPUPercent   float64      `json:"cpuPercent"`
+	Cores        []CoreUsage  `json:"cores"`
+	Load1        float64      `json:"load1"`
+	Load5        float64      `json:"load5"`
+	Load15       float64      `json:"load15"`
+	RunningProcs int          `json:"runningProcs"`
+	TotalProcs   int          `json:"totalProcs"`
+	Memory       SystemMemory `json:"memory"`
+	// Pressure is keyed by resource ("cpu", "memory", "io") and empty
+	// when the kernel has no PSI support
+	Pressure map[string]Pressure `json:"pressure"`
+	// ClaudeCPUPercent is the CPU used by all Claude process trees as a
+	// percentage of the whole machine
+	ClaudeCPUPercent float64 `json:"claudeCpuPercent"`
+	// ClaudeCPUShare is ClaudeCPUPercent as a percentage of the CPU time
+	// used by all processes
+	ClaudeCPUShare float64 `json:"claudeCpuShare"`
+}
+
+type cpuTicks struct {
+	busy  uint64
+	total uint64
+}
+
+// SystemMonitor reads machine-wide CPU, load, memory and pressure metrics
+type SystemMonitor struct {
+	mu       sync.Mutex
+	procRoot string
+	clkTck   float64
+	prev     map[string]cpuTicks
+	// prevClaude is the CPU time of each Claude process tree by identity
+	prevClaude map[string]float64
+	last       SystemStatus
+}
+
+// NewSystemMonitor creates a monitor reading the live /proc
+func NewSystemMonitor() *SystemMonitor {
+	return &SystemMonitor{
+		procRoot:   "/proc",
+		clkTck:     readClockTicks(),
+		prev:       make(map[string]cpuTicks),
+		prevClaude: make(map[string]float64),
+	}
+}
+
+// SetProcRoot changes the directory used in place of /proc
+func (sm *SystemMonitor) SetProcRoot(root string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.procRoot = root
+	sm.prev = make(map[string]cpuTicks)
+	sm.prevClaude = make(map[string]float64)
+}
+
+// Sample reads the current metrics. CPU utilization is computed since the
+// previous sample; processes are used for the Claude share of the CPU.
+func (sm *SystemMonitor) Sample(processes []ClaudeProcess) SystemStatus {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	status := SystemStatus{
+		Timestamp: time.Now().Unix(),
+		Cores:     []CoreUsage{},
+		Pressure:  make(map[string]Pressure),
+	}
+
+	busyDelta, totalDelta := sm.readCPU(&status)
+	sm.readLoad(&status)
+	status.Memory = readSystemMemory(sm.procRoot)
+	for _, resource := range []string{"cpu", "memory", "io"} {
+		if p, ok := readPressure(filepath.Join(sm.procRoot, "pressure", resource)); ok {
+			status.Pressure[resource] = p
+		}
+	}
+
+	// Claude CPU time covers the whole process trees and is compared with
+	// the same /proc/stat ticks as CPUPercent
+	var claudeTicks float64
+	current := make(map[string]float64, len(processes))
+	for _, p := range processes {
+		current[p.ID] = p.CPUSeconds
+		if prev, ok := sm.prevClaude[p.ID]; ok && p.CPUSeconds > prev {
+			claudeTicks += (p.CPUSeconds - prev) * sm.clkTck
+		}
+	}
+	sm.prevClaude = current
+
+	if totalDelta > 0 {
+		status.ClaudeCPUPercent = claudeTicks / totalDelta * 100
+	}
+	if busyDelta > 0 {
+		status.ClaudeCPUShare = claudeTicks / busyDelta * 100
+	}
+
+	sm.last = status
+	return status
+}
+
+// Last returns the result of the most recent sample
+func (sm *SystemMonitor) Last() SystemStatus {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.last
+}
+
+// readCPU computes total and per-core utilization from /proc/stat and
+// returns the busy and total ticks of all CPUs since the previous sample
+func (sm *SystemMonitor) readCPU(status *SystemStatus) (busyDelta, totalDelta float64) {
+	f, err := os.Open(filepath.Join(sm.procRoot, "stat"))
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	current := make(map[string]cpuTicks)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		busy, total := cpuLineTicks(fields[1:])
+		current[fields[0]] = cpuTicks{busy: busy, total: total}
+	}
+
+	for name, cur := range current {
+		prev, ok := sm.prev[name]
+		if !ok || cur.total <= prev.total || cur.busy < prev.busy {
+			continue
+		}
+		percent := float64(cur.busy-prev.busy) / float64(cur.total-prev.total) * 100
+
+		if name == "cpu" {
+			status.CPUPercent = percent
+			busyDelta = float64(cur.busy - prev.busy)
+			totalDelta = float64(cur.total - prev.total)
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(name, "cpu"))
+		if err != nil {
+			continue
+		}
+		status.Cores = append(status.Cores, CoreUsage{CPU: n, Percent: percent})
+	}
+	sort.Slice(status.Cores, func(i, j int) bool {
+		return status.Cores[i].CPU < status.Cores[j].CPU
+	})
+
+	sm.prev = current
+	return busyDelta, totalDelta
+}
+
+// readLoad parses /proc/loadavg: "0.52 0.58 0.59 2/1234 5678"
+func (sm *SystemMonitor) readLoad(status *SystemStatus) {
+	data, err := os.ReadFile(filepath.Join(sm.procRoot, "loadavg"))
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 4 {
+		return
+	}
+
+	status.Load1, _ = strconv.ParseFloat(fields[0], 64)
+	status.Load5, _ = strconv.ParseFloat(fields[1], 64)
+	status.Load15, _ = strconv.ParseFloat(fields[2], 64)
+	if running, total, ok := strings.Cut(fields[3], "/"); ok {
+		status.RunningProcs, _ = strconv.Atoi(running)
+		status.TotalProcs, _ = strconv.Atoi(total)
+	}
+}
+
+func readSystemMemory(procRoot string) SystemMemory {
+	info := readKBFields(filepath.Join(procRoot, "meminfo"))
+
+	mem := SystemMemory{
+		TotalMB:     kbToMB(info["MemTotal"]),
+		AvailableMB: kbToMB(info["MemAvailable"]),
+		BuffersMB:   kbToMB(info["Buffers"]),
+		CachedMB:    kbToMB(info["Cached"]),
+		SwapTotalMB: kbToMB(info["SwapTotal"]),
+	}
+	mem.UsedMB = mem.TotalMB - mem.AvailableMB
+	if mem.TotalMB > 0 {
+		mem.UsedPercent = mem.UsedMB / mem.TotalMB * 100
+	}
+	mem.SwapUsedMB = mem.SwapTotalMB - kbToMB(info["SwapFree"])
+	if mem.SwapTotalMB > 0 {
+		mem.SwapUsedPercent = mem.SwapUsedMB / mem.SwapTotalMB * 100
+	}
+
+	return mem
+}
+
+// readPressure parses a PSI file:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readPressure(path string) (Pressure, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Pressure{}, false
+	}
+
+	var p Pressure
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var pl PressureLine
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "avg10":
+				pl.Avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				pl.Avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				pl.Avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				pl.Total, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+
+		switch fields[0] {
+		case "some":
+			p.Some = pl
+			found = true
+		case "full":
+			p.Full = &pl
+		}
+	}
+
+	return p, found
+}
@@ -1,139 +1,296 @@
 package monitor
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Temperature backends
+const (
+	// TempBackendHwmon reads /sys/class/hwmon directly
+	TempBackendHwmon = "hwmon"
+	// TempBackendSensors runs `sensors -u` from lm-sensors
+	TempBackendSensors = "sensors"
+)
+
+// tempCacheTTL is how long a reading is reused, so that callers within the
+// same sample share one read of the sensors
+const tempCacheTTL = 2 * time.Second
+
 // Temperature represents a temperature reading
 type Temperature struct {
-	Label   string  `json:"label"`
-	Current float64 `json:"current"`
-	High    float64 `json:"high,omitempty"`
-	Crit    float64 `json:"crit,omitempty"`
+	Chip    string   `json:"chip,omitempty"`
+	Label   string   `json:"label"`
+	Current float64  `json:"current"`
+	High    *float64 `json:"high,omitempty"`
+	Crit    *float64 `json:"crit,omitempty"`
+	Alarm   bool     `json:"alarm,omitempty"`
+}
+
+// ID identifies a temperature sensor as "chip/label", like Sensor.ID
+func (t Temperature) ID() string {
+	return t.Chip + "/" + t.Label
+}
+
+// Aggregation modes for the main temperature
+const (
+	// TempAggregateSensor uses the selected sensor, or picks one
+	// automatically if none is selected
+	TempAggregateSensor = "sensor"
+	// TempAggregateMax uses the hottest CPU core
+	TempAggregateMax = "max"
+	// TempAggregateAvg uses the average of all CPU cores
+	TempAggregateAvg = "avg"
+)
+
+// TempSelection configures how the main temperature is determined
+type TempSelection struct {
+	// Sensor is the ID of the primary sensor; empty selects automatically
+	Sensor      string
+	Aggregation string
+}
+
+// TempCandidate is a sensor that can be selected as the primary sensor
+type TempCandidate struct {
+	ID       string  `json:"id"`
+	Chip     string  `json:"chip"`
+	Label    string  `json:"label"`
+	Current  float64 `json:"current"`
+	CPU      bool    `json:"cpu"`
+	Selected bool    `json:"selected"`
 }
 
 // TemperatureMonitor reads system temperatures
-type TemperatureMonitor struct{}
+type TemperatureMonitor struct {
+	mu        sync.Mutex
+	backend   string
+	hwmon     *HwmonCollector
+	cache     []Sensor
+	cachedAt  time.Time
+	selection TempSelection
+}
 
-// NewTemperatureMonitor creates a new temperature monitor
+// NewTemperatureMonitor creates a new temperature monitor using the hwmon
+// backend
 func NewTemperatureMonitor() *TemperatureMonitor {
-	return &TemperatureMonitor{}
+	return &TemperatureMonitor{
+		backend: TempBackendHwmon,
+		hwmon:   NewHwmonCollector(),
+	}
 }
 
-// GetTemperatures returns all available temperature readings
-func (tm *TemperatureMonitor) GetTemperatures() []Temperature {
-	// Try sensors command first
-	temps := tm.getSensorsTemperatures()
-	if len(temps) > 0 {
-		return temps
+// SetBackend selects TempBackendHwmon or TempBackendSensors
+func (tm *TemperatureMonitor) SetBackend(backend string) error {
+	if backend != TempBackendHwmon && backend != TempBackendSensors {
+		return fmt.Errorf("unknown temperature backend %q", backend)
 	}
 
-	// Fallback to sysfs
-	return tm.getSysfsTemperatures()
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.backend = backend
+	tm.cachedAt = time.Time{}
+	return nil
 }
 
-// GetMainTemperature returns the main CPU temperature
-func (tm *TemperatureMonitor) GetMainTemperature() float64 {
-	temps := tm.GetTemperatures()
+// SetSysfsRoot changes the directory used in place of /sys
+func (tm *TemperatureMonitor) SetSysfsRoot(root string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.hwmon.SysfsRoot = root
+	tm.cachedAt = time.Time{}
+}
 
-	// Priority order for main temp
-	priorities := []string{"Tctl", "Tdie", "Package", "Core 0", "CPU", "temp1"}
+// GetTemperatures returns all available temperature readings
+func (tm *TemperatureMonitor) GetTemperatures() []Temperature {
+	return temperaturesFromSensors(tm.GetSensors())
+}
 
-	for _, prio := range priorities {
-		for _, t := range temps {
-			if strings.Contains(t.Label, prio) {
-				return t.Current
-			}
-		}
-	}
+// GetSensors returns all temperature, fan, voltage, current and power
+// readings
+func (tm *TemperatureMonitor) GetSensors() []Sensor {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
 
-	// Return first temp if no priority match
-	if len(temps) > 0 {
-		return temps[0].Current
+	if time.Since(tm.cachedAt) > tempCacheTTL {
+		tm.cache = tm.readSensors()
+		tm.cachedAt = time.Now()
 	}
 
-	return 0
+	return append([]Sensor(nil), tm.cache...)
 }
 
-func (tm *TemperatureMonitor) getSensorsTemperatures() []Temperature {
-	cmd := exec.Command("sensors", "-u")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil
+func (tm *TemperatureMonitor) readSensors() []Sensor {
+	var sensors []Sensor
+	if tm.backend == TempBackendSensors {
+		sensors = tm.getSensorsReadings()
+	} else {
+		sensors = tm.hwmon.Sensors()
+	}
+	if len(temperaturesFromSensors(sensors)) > 0 {
+		return sensors
 	}
 
-	return parseSensorsOutput(string(output))
+	// Fallback to thermal zones for temperatures
+	return append(sensors, tm.getThermalZoneSensors()...)
 }
 
-func parseSensorsOutput(output string) []Temperature {
-	var temps []Temperature
-	lines := strings.Split(output, "\n")
+// GetMainTemperature returns the main CPU temperature according to the
+// configured selection, or false if there is no temperature sensor
+func (tm *TemperatureMonitor) GetMainTemperature() (float64, bool) {
+	tm.mu.Lock()
+	sel := tm.selection
+	tm.mu.Unlock()
+
+	return mainTemperature(tm.GetTemperatures(), sel)
+}
+
+// SetSelection configures how GetMainTemperature picks its value
+func (tm *TemperatureMonitor) SetSelection(sel TempSelection) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.selection = sel
+}
+
+// Candidates lists the temperature sensors that can be selected as the
+// primary sensor
+func (tm *TemperatureMonitor) Candidates() []TempCandidate {
+	tm.mu.Lock()
+	sel := tm.selection
+	tm.mu.Unlock()
+
+	candidates := []TempCandidate{}
+	for _, t := range tm.GetTemperatures() {
+		id := t.ID()
+		candidates = append(candidates, TempCandidate{
+			ID:       id,
+			Chip:     t.Chip,
+			Label:    t.Label,
+			Current:  t.Current,
+			CPU:      isCPUTemperature(t),
+			Selected: sel.Sensor == id,
+		})
+	}
+	return candidates
+}
 
-	var currentLabel string
-	var currentTemp Temperature
+func mainTemperature(temps []Temperature, sel TempSelection) (float64, bool) {
+	if len(temps) == 0 {
+		return 0, false
+	}
 
-	// Patterns for parsing sensors -u output
-	labelPattern := regexp.MustCompile(`^([^:]+):$`)
-	tempInputPattern := regexp.MustCompile(`^\s+temp\d+_input:\s+([\d.]+)$`)
-	tempMaxPattern := regexp.MustCompile(`^\s+temp\d+_max:\s+([\d.]+)$`)
-	tempCritPattern := regexp.MustCompile(`^\s+temp\d+_crit:\s+([\d.]+)$`)
+	switch sel.Aggregation {
+	case TempAggregateMax, TempAggregateAvg:
+		cores := cpuCoreTemperatures(temps)
+		if len(cores) == 0 {
+			break
+		}
+		var max, sum float64
+		for _, t := range cores {
+			sum += t.Current
+			if t.Current > max {
+				max = t.Current
+			}
+		}
+		if sel.Aggregation == TempAggregateMax {
+			return max, true
+		}
+		return sum / float64(len(cores)), true
+	}
 
-	for _, line := range lines {
-		// Check for label line
-		if matches := labelPattern.FindStringSubmatch(line); len(matches) > 1 {
-			// Save previous temp if valid
-			if currentLabel != "" && currentTemp.Current > 0 {
-				currentTemp.Label = currentLabel
-				temps = append(temps, currentTemp)
+	if sel.Sensor != "" {
+		for _, t := range temps {
+			if t.ID() == sel.Sensor {
+				return t.Current, true
 			}
-			currentLabel = matches[1]
-			currentTemp = Temperature{}
-			continue
 		}
+	}
 
-		// Check for temperature input
-		if matches := tempInputPattern.FindStringSubmatch(line); len(matches) > 1 {
-			val, _ := strconv.ParseFloat(matches[1], 64)
-			if val > 0 && val < 150 { // Sanity check
-				currentTemp.Current = val
+	// Priority order for main temp, CPU chips first so that e.g. an NVMe
+	// temp1 is not picked over the CPU package
+	priorities := []string{"Tctl", "Tdie", "Package", "Core 0", "CPU", "temp1"}
+
+	var cpuTemps []Temperature
+	for _, t := range temps {
+		if isCPUTemperature(t) {
+			cpuTemps = append(cpuTemps, t)
+		}
+	}
+
+	for _, candidates := range [][]Temperature{cpuTemps, temps} {
+		for _, prio := range priorities {
+			for _, t := range candidates {
+				if strings.Contains(t.Label, prio) {
+					return t.Current, true
+				}
 			}
-			continue
 		}
+	}
 
-		// Check for max temperature
-		if matches := tempMaxPattern.FindStringSubmatch(line); len(matches) > 1 {
-			val, _ := strconv.ParseFloat(matches[1], 64)
-			currentTemp.High = val
-			continue
+	// Return first temp if no priority match
+	if len(cpuTemps) > 0 {
+		return cpuTemps[0].Current, true
+	}
+	return temps[0].Current, true
+}
+
+// cpuChipPrefixes are hwmon drivers and thermal zone types of CPUs
+var cpuChipPrefixes = []string{"coretemp", "k10temp", "zenpower", "cpu_thermal", "cpu-thermal", "x86_pkg_temp"}
+
+// isCPUTemperature reports whether t belongs to a CPU chip
+func isCPUTemperature(t Temperature) bool {
+	for _, prefix := range cpuChipPrefixes {
+		if strings.HasPrefix(t.Chip, prefix) || strings.HasPrefix(t.Label, prefix) {
+			return true
 		}
+	}
+	return false
+}
 
-		// Check for critical temperature
-		if matches := tempCritPattern.FindStringSubmatch(line); len(matches) > 1 {
-			val, _ := strconv.ParseFloat(matches[1], 64)
-			currentTemp.Crit = val
+// cpuCoreTemperatures returns the per-core (or per-CCD) sensors of the CPU,
+// or all CPU sensors if there are no per-core ones
+func cpuCoreTemperatures(temps []Temperature) []Temperature {
+	var cores, cpu []Temperature
+	for _, t := range temps {
+		if !isCPUTemperature(t) {
 			continue
 		}
+		cpu = append(cpu, t)
+		if strings.HasPrefix(t.Label, "Core ") || strings.HasPrefix(t.Label, "Tccd") {
+			cores = append(cores, t)
+		}
 	}
+	if len(cores) > 0 {
+		return cores
+	}
+	return cpu
+}
 
-	// Don't forget the last one
-	if currentLabel != "" && currentTemp.Current > 0 {
-		currentTemp.Label = currentLabel
-		temps = append(temps, currentTemp)
+// CelsiusToFahrenheit converts a temperature from °C to °F
+func CelsiusToFahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+func (tm *TemperatureMonitor) getSensorsReadings() []Sensor {
+	cmd := exec.Command("sensors", "-u")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
 	}
 
-	return temps
+	return parseSensorsOutput(string(output))
 }
 
-func (tm *TemperatureMonitor) getSysfsTemperatures() []Temperature {
-	var temps []Temperature
+func (tm *TemperatureMonitor) getThermalZoneSensors() []Sensor {
+	var sensors []Sensor
 
 	// Find thermal zones
-	zones, err := filepath.Glob("/sys/class/thermal/thermal_zone*/temp")
+	zones, err := filepath.Glob(filepath.Join(tm.hwmon.SysfsRoot, "class", "thermal", "thermal_zone*", "temp"))
 	if err != nil {
 		return nil
 	}
@@ -160,11 +317,14 @@ func (tm *TemperatureMonitor) getSysfsTemperatures() []Temperature {
 			label = filepath.Base(zoneDir)
 		}
 
-		temps = append(temps, Temperature{
-			Label:   label,
-			Current: tempVal,
+		sensors = append(sensors, Sensor{
+			Chip:  filepath.Base(zoneDir),
+			Label: label,
+			Type:  SensorTemperature,
+			Value: tempVal,
+			Unit:  "°C",
 		})
 	}
 
-	return temps
+	return sensors
 }
@@ -0,0 +1,183 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Terminal multiplexers
+const (
+	MultiplexerTmux   = "tmux"
+	MultiplexerScreen = "screen"
+)
+
+// TerminalInfo is where a session is displayed
+type TerminalInfo struct {
+	// TTY is the controlling terminal, e.g. "/dev/pts/3"
+	TTY         string `json:"tty,omitempty"`
+	Multiplexer string `json:"multiplexer,omitempty"`
+	// Emulator is the terminal emulator, IDE or sshd found among the
+	// ancestors; empty inside a multiplexer, whose clients are elsewhere
+	Emulator string `json:"emulator,omitempty"`
+	// Screen is the GNU screen session name from $STY
+	Screen string    `json:"screen,omitempty"`
+	Tmux   *TmuxPane `json:"tmux,omitempty"`
+}
+
+// terminalEmulators maps the comm of known terminal hosts to a name
+var terminalEmulators = map[string]string{
+	"gnome-terminal-": "gnome-terminal",
+	"konsole":         "konsole",
+	"kitty":           "kitty",
+	"alacritty":       "alacritty",
+	"wezterm-gui":     "wezterm",
+	"foot":            "foot",
+	"xterm":           "xterm",
+	"urxvt":           "urxvt",
+	"terminator":      "terminator",
+	"tilix":           "tilix",
+	"xfce4-terminal":  "xfce4-terminal",
+	"ghostty":         "ghostty",
+	"code":            "vscode",
+	"cursor":          "cursor",
+	"sshd":            "ssh",
+}
+
+// maxAncestors bounds the walk up the process tree
+const maxAncestors = 64
+
+// terminalTarget is the terminal of a process as found at its first scan.
+// The tmux socket and pane ID are kept for actions but not exposed.
+type terminalTarget struct {
+	info   TerminalInfo
+	socket string
+	paneID string
+}
+
+// readTerminal resolves the TTY and the multiplexer or emulator hosting a
+// process, or returns nil if it has no terminal
+func readTerminal(procRoot string, pid int, st procStat) *terminalTarget {
+	info := TerminalInfo{TTY: ttyName(st.ttyNr)}
+	if info.TTY == "" {
+		// Fall back to stdin, e.g. when the process called setsid
+		if target, err := os.Readlink(filepath.Join(procRoot, strconv.Itoa(pid), "fd", "0")); err == nil &&
+			(strings.HasPrefix(target, "/dev/pts/") || strings.HasPrefix(target, "/dev/tty")) {
+			info.TTY = target
+		}
+	}
+
+	serverPID := 0
+	for ppid, n := st.ppid, 0; ppid > 1 && n < maxAncestors; n++ {
+		comm := readComm(procRoot, ppid)
+		switch {
+		case strings.HasPrefix(comm, "tmux"):
+			info.Multiplexer = MultiplexerTmux
+			serverPID = ppid
+		case strings.EqualFold(comm, "screen"):
+			info.Multiplexer = MultiplexerScreen
+		default:
+			if name, ok := terminalEmulators[comm]; ok && info.Emulator == "" && info.Multiplexer == "" {
+				info.Emulator = name
+			}
+		}
+		if info.Multiplexer != "" {
+			break
+		}
+
+		parent, err := readProcStat(procRoot, ppid)
+		if err != nil {
+			break
+		}
+		ppid = parent.ppid
+	}
+
+	if info.Multiplexer == "" && info.TTY == "" && info.Emulator == "" {
+		return nil
+	}
+
+	t := &terminalTarget{info: info}
+	env := readEnv(procRoot, pid, "TMUX", "TMUX_PANE", "STY")
+	switch info.Multiplexer {
+	case MultiplexerScreen:
+		t.info.Screen = env["STY"]
+	case MultiplexerTmux:
+		// $TMUX is "socket,server pid,session"; without access to the
+		// environment assume the default socket of the server's owner
+		t.socket, _, _ = strings.Cut(env["TMUX"], ",")
+		if t.socket == "" {
+			t.socket = defaultTmuxSocket(procRoot, serverPID)
+		}
+		t.paneID = env["TMUX_PANE"]
+	}
+
+	return t
+}
+
+// resolve returns the terminal with the current location of the tmux
+// pane, which changes when windows are moved or renumbered
+func (t *terminalTarget) resolve(tmux *TmuxClient) *TerminalInfo {
+	info := t.info
+	if t.socket != "" {
+		if info.Tmux = tmux.Pane(t.socket, t.paneID, info.TTY); info.Tmux != nil {
+			t.paneID = info.Tmux.PaneID
+		}
+	}
+	return &info
+}
+
+// ttyName decodes the tty_nr field of /proc/{pid}/stat, 0 for none
+func ttyName(ttyNr int) string {
+	if ttyNr == 0 {
+		return ""
+	}
+	major := (ttyNr >> 8) & 0xfff
+	minor := (ttyNr & 0xff) | ((ttyNr >> 12) & 0xfff00)
+
+	switch {
+	case major >= 136 && major <= 143: // Unix98 pseudo-terminals
+		return "/dev/pts/" + strconv.Itoa((major-136)*256+minor)
+	case major == 4 && minor < 64:
+		return "/dev/tty" + strconv.Itoa(minor)
+	case major == 4:
+		return "/dev/ttyS" + strconv.Itoa(minor-64)
+	}
+	return ""
+}
+
+// readEnv returns the named variables of a process for internal use; they
+// are not exposed by the API
+func readEnv(procRoot string, pid int, names ...string) map[string]string {
+	env := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return env
+	}
+	for _, kv := range splitNul(data) {
+		name, value, _ := strings.Cut(kv, "=")
+		for _, n := range names {
+			if n == name {
+				env[name] = value
+			}
+		}
+	}
+	return env
+}
+
+// defaultTmuxSocket returns the socket a tmux server uses without -L or -S
+func defaultTmuxSocket(procRoot string, serverPID int) string {
+	if serverPID == 0 {
+		return ""
+	}
+	status := readKBFields(filepath.Join(procRoot, strconv.Itoa(serverPID), "status"))
+	uid, ok := status["Uid"]
+	if !ok {
+		return ""
+	}
+	dir := os.Getenv("TMUX_TMPDIR")
+	if dir == "" {
+		dir = "/tmp"
+	}
+	return filepath.Join(dir, "tmux-"+strconv.FormatUint(uid, 10), "default")
+}
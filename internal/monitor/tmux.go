@@ -0,0 +1,144 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tmuxCacheTTL = 10 * time.Second
+	tmuxTimeout  = 2 * time.Second
+)
+
+// ErrNoTmuxPane is returned for actions on a session outside of tmux
+var ErrNoTmuxPane = errors.New("session is not running in a tmux pane")
+
+// TmuxPane is the location of a session in tmux
+type TmuxPane struct {
+	Session string `json:"session"`
+	Window  int    `json:"window"`
+	Pane    int    `json:"pane"`
+	// PaneID is the unique pane ID, e.g. "%3", which survives moving the
+	// pane to another window
+	PaneID string `json:"paneId"`
+	// Target is "session:window.pane" as used with tmux -t
+	Target string `json:"target"`
+}
+
+type tmuxPanes struct {
+	at    time.Time
+	panes []TmuxPane
+	ttys  []string
+}
+
+// TmuxClient queries tmux servers by socket path. Pane lists are cached
+// briefly, as every scan looks up every session.
+type TmuxClient struct {
+	mu      sync.Mutex
+	servers map[string]tmuxPanes
+}
+
+// NewTmuxClient creates a client with an empty cache
+func NewTmuxClient() *TmuxClient {
+	return &TmuxClient{servers: make(map[string]tmuxPanes)}
+}
+
+// Pane finds a pane by its ID, or by its TTY when the ID is unknown
+func (c *TmuxClient) Pane(socket, paneID, tty string) *TmuxPane {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	server, ok := c.servers[socket]
+	if !ok || time.Since(server.at) > tmuxCacheTTL {
+		server = tmuxPanes{at: time.Now()}
+		server.panes, server.ttys, _ = listTmuxPanes(socket)
+		c.servers[socket] = server
+	}
+	// Forget servers that have not been asked about in a while
+	for s, p := range c.servers {
+		if time.Since(p.at) > 6*tmuxCacheTTL {
+			delete(c.servers, s)
+		}
+	}
+
+	for i, p := range server.panes {
+		if (paneID != "" && p.PaneID == paneID) || (paneID == "" && tty != "" && server.ttys[i] == tty) {
+			pane := p
+			return &pane
+		}
+	}
+	return nil
+}
+
+// SelectPane makes the pane the active one of its window and the window
+// the current one of its session
+func (c *TmuxClient) SelectPane(socket, paneID string) error {
+	if _, err := runTmux(socket, "select-window", "-t", paneID); err != nil {
+		return err
+	}
+	_, err := runTmux(socket, "select-pane", "-t", paneID)
+	return err
+}
+
+// SendKeys types keys into the pane. Keys are tmux key names such as
+// "Enter" or "C-c" unless literal is set.
+func (c *TmuxClient) SendKeys(socket, paneID string, keys []string, literal bool) error {
+	args := []string{"send-keys", "-t", paneID}
+	if literal {
+		args = append(args, "-l")
+	}
+	args = append(args, "--")
+	_, err := runTmux(socket, append(args, keys...)...)
+	return err
+}
+
+// listTmuxPanes lists the panes of all sessions with their TTYs
+func listTmuxPanes(socket string) ([]TmuxPane, []string, error) {
+	output, err := runTmux(socket, "list-panes", "-a", "-F",
+		"#{pane_id}\t#{pane_tty}\t#{session_name}\t#{window_index}\t#{pane_index}")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var panes []TmuxPane
+	var ttys []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		window, _ := strconv.Atoi(fields[3])
+		pane, _ := strconv.Atoi(fields[4])
+		panes = append(panes, TmuxPane{
+			Session: fields[2],
+			Window:  window,
+			Pane:    pane,
+			PaneID:  fields[0],
+			Target:  fmt.Sprintf("%s:%d.%d", fields[2], window, pane),
+		})
+		ttys = append(ttys, fields[1])
+	}
+	return panes, ttys, nil
+}
+
+func runTmux(socket string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tmuxTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "tmux", append([]string{"-S", socket}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("tmux %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("tmux %s: %w", args[0], err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,208 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// maxTranscriptBytes bounds the transcript data read per scan, so that
+	// the first scan of a large history is spread over several samples
+	maxTranscriptBytes = 8 << 20
+	// transcriptSeenTTL is how long message IDs are remembered to skip
+	// the copies of a message in resumed and forked transcripts
+	transcriptSeenTTL = 48 * time.Hour
+	// transcriptPruneInterval is how often positions of deleted
+	// transcripts are dropped
+	transcriptPruneInterval = time.Hour
+)
+
+// TokenCount is the token usage reported by the API for Claude's requests
+type TokenCount struct {
+	Input         int64 `json:"input"`
+	Output        int64 `json:"output"`
+	CacheRead     int64 `json:"cacheRead"`
+	CacheCreation int64 `json:"cacheCreation"`
+}
+
+// Add adds the counts of o
+func (t *TokenCount) Add(o TokenCount) {
+	t.Input += o.Input
+	t.Output += o.Output
+	t.CacheRead += o.CacheRead
+	t.CacheCreation += o.CacheCreation
+}
+
+// tokenUsage is the usage of one API response in a transcript
+type tokenUsage struct {
+	cwd    string
+	at     time.Time
+	tokens TokenCount
+}
+
+// transcriptLine holds the fields of a transcript entry that carry usage
+type transcriptLine struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Cwd       string    `json:"cwd"`
+	RequestID string    `json:"requestId"`
+	Message   struct {
+		ID    string `json:"id"`
+		Usage *struct {
+			InputTokens              int64 `json:"input_tokens"`
+			OutputTokens             int64 `json:"output_tokens"`
+			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+// transcriptReader follows the session transcripts Claude writes to
+// <config dir>/projects/<dir>/<session>.jsonl and returns the usage of
+// responses appended since the last read
+type transcriptReader struct {
+	// offsets is the read position of each transcript
+	offsets map[string]int64
+	// seen holds the usage counted per message. A response is written as
+	// one line per content block, each with the usage up to that block.
+	seen      map[string]seenMessage
+	lastPrune time.Time
+}
+
+type seenMessage struct {
+	at     time.Time
+	tokens TokenCount
+}
+
+func newTranscriptReader() *transcriptReader {
+	return &transcriptReader{
+		offsets: make(map[string]int64),
+		seen:    make(map[string]seenMessage),
+	}
+}
+
+// read returns new usage in the transcripts of the given config dirs.
+// Transcripts not modified since notBefore are skipped when seen for the
+// first time.
+func (t *transcriptReader) read(configDirs []string, notBefore time.Time) []tokenUsage {
+	var usage []tokenUsage
+	budget := int64(maxTranscriptBytes)
+
+	for _, dir := range configDirs {
+		files, _ := filepath.Glob(filepath.Join(dir, "projects", "*", "*.jsonl"))
+		for _, path := range files {
+			if budget <= 0 {
+				break
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			offset, known := t.offsets[path]
+			if !known && info.ModTime().Before(notBefore) {
+				t.offsets[path] = info.Size()
+				continue
+			}
+			if info.Size() < offset {
+				offset = 0 // Rewritten
+			}
+			if info.Size() == offset {
+				continue
+			}
+
+			read, found := t.readFile(path, offset, budget)
+			t.offsets[path] = offset + read
+			budget -= read
+			usage = append(usage, found...)
+		}
+	}
+
+	for id, m := range t.seen {
+		if time.Since(m.at) > transcriptSeenTTL {
+			delete(t.seen, id)
+		}
+	}
+	if time.Since(t.lastPrune) > transcriptPruneInterval {
+		for path := range t.offsets {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				delete(t.offsets, path)
+			}
+		}
+		t.lastPrune = time.Now()
+	}
+	return usage
+}
+
+// readFile reads complete lines from offset, at most limit bytes, and
+// returns the number of bytes consumed
+func (t *transcriptReader) readFile(path string, offset, limit int64) (int64, []tokenUsage) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, nil
+	}
+
+	var usage []tokenUsage
+	var read int64
+	r := bufio.NewReaderSize(io.LimitReader(f, limit), 64*1024)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			// A partial line is read again once it is complete. Lines
+			// larger than the budget are skipped.
+			if read == 0 && limit == maxTranscriptBytes && int64(len(line)) == limit {
+				read = limit
+			}
+			break
+		}
+		read += int64(len(line))
+
+		if !bytes.Contains(line, []byte(`"usage"`)) {
+			continue
+		}
+		var entry transcriptLine
+		if json.Unmarshal(line, &entry) != nil || entry.Type != "assistant" || entry.Message.Usage == nil {
+			continue
+		}
+		u := entry.Message.Usage
+		tokens := TokenCount{
+			Input:         u.InputTokens,
+			Output:        u.OutputTokens,
+			CacheRead:     u.CacheReadInputTokens,
+			CacheCreation: u.CacheCreationInputTokens,
+		}
+
+		// Count only what the message used beyond earlier lines
+		id := entry.Message.ID + "/" + entry.RequestID
+		prev := t.seen[id].tokens
+		t.seen[id] = seenMessage{at: time.Now(), tokens: maxTokens(prev, tokens)}
+		delta := TokenCount{
+			Input:         max(tokens.Input-prev.Input, 0),
+			Output:        max(tokens.Output-prev.Output, 0),
+			CacheRead:     max(tokens.CacheRead-prev.CacheRead, 0),
+			CacheCreation: max(tokens.CacheCreation-prev.CacheCreation, 0),
+		}
+		if delta == (TokenCount{}) {
+			continue
+		}
+		usage = append(usage, tokenUsage{cwd: entry.Cwd, at: entry.Timestamp, tokens: delta})
+	}
+	return read, usage
+}
+
+func maxTokens(a, b TokenCount) TokenCount {
+	return TokenCount{
+		Input:         max(a.Input, b.Input),
+		Output:        max(a.Output, b.Output),
+		CacheRead:     max(a.CacheRead, b.CacheRead),
+		CacheCreation: max(a.CacheCreation, b.CacheCreation),
+	}
+}
@@ -0,0 +1,128 @@
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procStat holds the fields of /proc/{pid}/stat used by the monitor
+type procStat struct {
+	state     byte
+	ppid      int
+	ttyNr     int
+	utime     uint64
+	stime     uint64
+	cutime    uint64
+	cstime    uint64
+	starttime uint64
+	// exitCode is the wait status of a zombie, 0 on kernels before 3.5
+	exitCode int
+}
+
+// treeTicks is the CPU time of the process including its waited-for
+// children, in clock ticks
+func (st procStat) treeTicks() uint64 {
+	return st.utime + st.stime + st.cutime + st.cstime
+}
+
+func readProcStat(procRoot string, pid int) (procStat, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+	return parseProcStat(string(data))
+}
+
+func parseProcStat(content string) (procStat, error) {
+	// The command name may contain spaces and parentheses, so split after
+	// the last closing parenthesis
+	idx := strings.LastIndex(content, ")")
+	if idx == -1 || idx+2 >= len(content) {
+		return procStat{}, fmt.Errorf("malformed stat line")
+	}
+
+	fields := strings.Fields(content[idx+2:])
+	if len(fields) < 20 {
+		return procStat{}, fmt.Errorf("short stat line")
+	}
+
+	var st procStat
+	st.state = fields[0][0]
+	st.ppid, _ = strconv.Atoi(fields[1])
+	st.ttyNr, _ = strconv.Atoi(fields[4])
+	st.utime, _ = strconv.ParseUint(fields[11], 10, 64)
+	st.stime, _ = strconv.ParseUint(fields[12], 10, 64)
+	st.cutime, _ = strconv.ParseUint(fields[13], 10, 64)
+	st.cstime, _ = strconv.ParseUint(fields[14], 10, 64)
+	st.starttime, _ = strconv.ParseUint(fields[19], 10, 64)
+	if len(fields) > 49 {
+		st.exitCode, _ = strconv.Atoi(fields[49])
+	}
+
+	return st, nil
+}
+
+// processTree holds the parent/child relation and stat of every process
+type processTree struct {
+	children map[int][]int
+	stats    map[int]procStat
+}
+
+// readProcessTree builds the parent/child relation for every process
+// visible under procRoot
+func readProcessTree(procRoot string) (processTree, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return processTree{}, fmt.Errorf("failed to read %s: %w", procRoot, err)
+	}
+
+	tree := processTree{
+		children: make(map[int][]int),
+		stats:    make(map[int]procStat),
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		st, err := readProcStat(procRoot, pid)
+		if err != nil {
+			continue
+		}
+		tree.children[st.ppid] = append(tree.children[st.ppid], pid)
+		tree.stats[pid] = st
+	}
+
+	return tree, nil
+}
+
+// Descendants returns all transitive children of pid, excluding pid itself
+func (t processTree) Descendants(pid int) []int {
+	var result []int
+	queue := append([]int(nil), t.children[pid]...)
+	seen := map[int]bool{pid: true}
+	for len(queue) > 0 {
+		child := queue[0]
+		queue = queue[1:]
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		result = append(result, child)
+		queue = append(queue, t.children[child]...)
+	}
+	return result
+}
+
+// CPUTicks returns the CPU time of pid and all its descendants, including
+// children that have already exited and been waited for
+func (t processTree) CPUTicks(pid int) uint64 {
+	total := t.stats[pid].treeTicks()
+	for _, child := range t.Descendants(pid) {
+		total += t.stats[child].treeTicks()
+	}
+	return total
+}
@@ -0,0 +1,244 @@
+package monitor
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPeerNotFound is returned when the owner of a client socket cannot be
+// determined, e.g. because the client is not on this machine
+var ErrPeerNotFound = errors.New("socket of the peer not found")
+
+// UserUsage is the resource use of all sessions of one user
+type UserUsage struct {
+	UID             int     `json:"uid"`
+	User            string  `json:"user"`
+	Sessions        int     `json:"sessions"`
+	CPUPercent      float64 `json:"cpuPercent"`
+	CPUPercentTotal float64 `json:"cpuPercentTotal"`
+	// CPUSharePercent is the user's share of the CPU used by all Claude
+	// sessions
+	CPUSharePercent float64 `json:"cpuSharePercent"`
+	CPUSeconds      float64 `json:"cpuSeconds"`
+	MemoryMB        float64 `json:"memoryMb"`
+	PowerWatts      float64 `json:"powerWatts"`
+}
+
+// PasswdDB resolves user names from a passwd file without cgo or NSS. It
+// is re-read when the file changes. Users only known to LDAP or similar
+// are reported by their numeric UID.
+type PasswdDB struct {
+	mu     sync.Mutex
+	path   string
+	mtime  time.Time
+	byUID  map[int]string
+	byName map[string]int
+	homes  map[int]string
+}
+
+// NewPasswdDB creates a database reading /etc/passwd
+func NewPasswdDB() *PasswdDB {
+	return &PasswdDB{path: "/etc/passwd"}
+}
+
+// SetPath points the database at another passwd file
+func (db *PasswdDB) SetPath(path string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.path = path
+	db.mtime = time.Time{}
+}
+
+// Name returns the user name of uid, or the UID as a string if unknown
+func (db *PasswdDB) Name(uid int) string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.refresh()
+	if name, ok := db.byUID[uid]; ok {
+		return name
+	}
+	return strconv.Itoa(uid)
+}
+
+// UID resolves a user name or a numeric UID
+func (db *PasswdDB) UID(user string) (int, bool) {
+	if uid, err := strconv.Atoi(user); err == nil {
+		return uid, true
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.refresh()
+	uid, ok := db.byName[user]
+	return uid, ok
+}
+
+// Home returns the home directory of uid, or "" if unknown
+func (db *PasswdDB) Home(uid int) string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.refresh()
+	return db.homes[uid]
+}
+
+func (db *PasswdDB) refresh() {
+	info, err := os.Stat(db.path)
+	if err != nil || info.ModTime().Equal(db.mtime) {
+		return
+	}
+	f, err := os.Open(db.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	byUID := make(map[int]string)
+	byName := make(map[string]int)
+	homes := make(map[int]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		if _, ok := byUID[uid]; !ok {
+			byUID[uid] = fields[0]
+			if len(fields) > 5 {
+				homes[uid] = fields[5]
+			}
+		}
+		byName[fields[0]] = uid
+	}
+	db.byUID, db.byName, db.homes, db.mtime = byUID, byName, homes, info.ModTime()
+}
+
+// readOwner returns the real UID of a process
+func readOwner(procRoot string, pid int) (int, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "Uid:"); ok {
+			// Real, effective, saved and filesystem UID
+			fields := strings.Fields(rest)
+			if len(fields) > 0 {
+				return strconv.Atoi(fields[0])
+			}
+		}
+	}
+	return 0, errors.New("no Uid in status")
+}
+
+// AggregateUsers sums up processes per owner, busiest first
+func AggregateUsers(processes []ClaudeProcess) []UserUsage {
+	byUID := make(map[int]*UserUsage)
+	var totalCPU float64
+	for _, p := range processes {
+		u, ok := byUID[p.UID]
+		if !ok {
+			u = &UserUsage{UID: p.UID, User: p.User}
+			byUID[p.UID] = u
+		}
+		u.Sessions++
+		u.CPUPercent += p.CPUPercent
+		u.CPUPercentTotal += p.CPUPercentTotal
+		u.CPUSeconds += p.CPUSeconds
+		u.MemoryMB += p.MemoryMB
+		u.PowerWatts += p.PowerWatts
+		totalCPU += p.CPUPercent
+	}
+
+	users := make([]UserUsage, 0, len(byUID))
+	for _, u := range byUID {
+		if totalCPU > 0 {
+			u.CPUSharePercent = u.CPUPercent / totalCPU * 100
+		}
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].CPUPercent != users[j].CPUPercent {
+			return users[i].CPUPercent > users[j].CPUPercent
+		}
+		return users[i].UID < users[j].UID
+	})
+	return users
+}
+
+// PeerUID returns the owner of the client end of a TCP connection to this
+// machine. The kernel records the UID of every socket in /proc/net/tcp,
+// which identifies local users, including those connecting through an SSH
+// port forward, without passwords.
+func PeerUID(procRoot string, local, remote *net.TCPAddr) (int, error) {
+	if !remote.IP.IsLoopback() && !isLocalAddress(remote.IP) {
+		return 0, ErrPeerNotFound
+	}
+	for _, proto := range []string{"tcp", "tcp6"} {
+		if uid, ok := findSocketOwner(filepath.Join(procRoot, "net", proto), remote, local); ok {
+			return uid, nil
+		}
+	}
+	return 0, ErrPeerNotFound
+}
+
+// findSocketOwner looks up the socket whose local end is from and whose
+// remote end is to
+func findSocketOwner(path string, from, to *net.TCPAddr) (int, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Skip header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx:rx tr:when retrnsmt uid ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		if !sameEndpoint(fields[1], from) || !sameEndpoint(fields[2], to) {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[7])
+		if err != nil {
+			return 0, false
+		}
+		return uid, true
+	}
+	return 0, false
+}
+
+func sameEndpoint(hexAddr string, addr *net.TCPAddr) bool {
+	ip, port, err := parseHexAddress(hexAddr)
+	// IPv4 clients of a dual-stack listener appear as ::ffff:a.b.c.d
+	return err == nil && port == addr.Port && net.ParseIP(ip).Equal(addr.IP)
+}
+
+// isLocalAddress reports whether ip is assigned to an interface of this
+// machine, as when a browser on the server uses its external address
+func isLocalAddress(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
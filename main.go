@@ -7,6 +7,9 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"claude-monitor/internal/api"
@@ -18,13 +21,29 @@ var staticFS embed.FS
 
 func main() {
 	port := flag.Int("port", 8080, "HTTP server port")
+	tempBackend := flag.String("temp-backend", monitor.TempBackendHwmon, "Temperature source: hwmon or sensors")
+	procConnector := flag.Bool("proc-connector", true, "Receive process start/exit events over netlink when permitted")
 	flag.Parse()
 
 	// Initialize monitors
 	processMonitor := monitor.NewProcessMonitor()
 	tempMonitor := monitor.NewTemperatureMonitor()
+	if err := tempMonitor.SetBackend(*tempBackend); err != nil {
+		log.Fatalf("Invalid -temp-backend: %v", err)
+	}
 	historyBuffer := monitor.NewHistoryBuffer()
 
+	if *procConnector {
+		done, err := processMonitor.StartProcConnector()
+		if err != nil {
+			log.Printf("Process connector unavailable, polling /proc only: %v", err)
+		} else {
+			go func() {
+				log.Printf("Process connector stopped, polling /proc only: %v", <-done)
+			}()
+		}
+	}
+
 	// Initialize API handler
 	handler := api.NewHandler(processMonitor, tempMonitor, historyBuffer)
 
@@ -57,18 +76,26 @@ func main() {
 
 		for range ticker.C {
 			handler.RecordHistory()
+			handler.ApplyPolicies()
 
 			// Check alerts (could be extended to log or send notifications)
-			cpuAlert, tempAlert, process := handler.CheckAlerts()
-			if cpuAlert {
-				log.Printf("ALERT: High CPU usage on process %s", process)
-			}
-			if tempAlert {
-				log.Printf("ALERT: High temperature detected")
+			for _, alert := range handler.CheckAlerts() {
+				log.Printf("ALERT: %s", alert.Message)
 			}
 		}
 	}()
 
+	// Never leave sessions stopped by a policy behind, and keep the
+	// project totals of the last minute
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		handler.ResumeSuspended()
+		handler.SaveState()
+		os.Exit(0)
+	}()
+
 	// Start server
 	addr := fmt.Sprintf(":%d", *port)
 	log.Printf("Starting Claude Monitor on http://localhost%s", addr)